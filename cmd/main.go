@@ -1,16 +1,495 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/rpc"
+	"github.com/khushbakhtmahkamov/wallet/pkg/server"
+	"github.com/khushbakhtmahkamov/wallet/pkg/shell"
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
 	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
 )
 
-func main()  {
-	svc:=&wallet.Service{}
-	account, err :=svc.RegisterAccount("+992928393813")
+//Exit codes
+const (
+	exitOK        = 0
+	exitFailure   = 1
+	exitUsage     = 2
+	exitNotFound  = 3
+	exitInvalid   = 4
+	exitConflict  = 5
+	exitNoBalance = 6
+	exitFrozen    = 7
+	exitForbidden = 8
+)
+
+const (
+	defaultDataDir   = "data"
+	defaultServeAddr = ":9999"
+)
+
+const usage = `usage: wallet [-data dir] [-json] command [args]
+
+commands:
+  register <phone>
+  deposit <accountID> <amount>
+  pay <accountID> <amount> <category>
+  reject <paymentID>
+  repeat <paymentID>
+  favorite <paymentID> <name>
+  history <accountID>
+  export <dir>
+  import <dir>
+  sum [goroutines]
+  serve [addr]
+  rpc <tcp|unix> <address>
+  shell
+`
+
+var errUsage = errors.New("wrong usage")
+
+type cli struct {
+	dir     string
+	json    bool
+	stdout  io.Writer
+	svc     *wallet.Service
+	changed bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+//run executes command line and returns exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("wallet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	dir := flags.String("data", defaultDataDir, "data directory")
+	asJSON := flags.Bool("json", false, "print JSON instead of table")
+	err := flags.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	c := &cli{dir: *dir, json: *asJSON, stdout: stdout, svc: &wallet.Service{}}
+	err = c.load()
+	if err == nil {
+		err = c.exec(flags.Arg(0), flags.Args()[1:])
+	}
+	if err == nil && c.changed {
+		err = c.save()
+	}
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(stderr, err)
+			fmt.Fprint(stderr, usage)
+		} else {
+			fmt.Fprintln(stderr, "error:", err)
+		}
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+//exitCode maps error to process exit code
+func exitCode(err error) int {
+
+	if errors.Is(err, errUsage) {
+		return exitUsage
+	}
+	switch wallet.HTTPStatus(err) {
+	case http.StatusNotFound:
+		return exitNotFound
+	case http.StatusBadRequest:
+		return exitInvalid
+	case http.StatusConflict:
+		return exitConflict
+	case http.StatusUnprocessableEntity:
+		return exitNoBalance
+	case http.StatusLocked:
+		return exitFrozen
+	case http.StatusForbidden:
+		return exitForbidden
+	}
+	return exitFailure
+}
+
+func (c *cli) load() error {
+
+	_, err := os.Stat(c.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.svc.Import(c.dir)
+}
+
+func (c *cli) save() error {
+
+	err := os.MkdirAll(c.dir, 0777)
+	if err != nil {
+		return err
+	}
+	return c.svc.Export(c.dir)
+}
+
+func (c *cli) exec(command string, args []string) error {
+
+	switch command {
+	case "register":
+		if len(args) != 1 {
+			return usageError(command)
+		}
+		account, err := c.svc.RegisterAccount(types.Phone(args[0]))
+		if err != nil {
+			return err
+		}
+		c.changed = true
+		return c.printAccount(*account)
+
+	case "deposit":
+		if len(args) != 2 {
+			return usageError(command)
+		}
+		accountID, err := parseID(args[0])
+		if err != nil {
+			return err
+		}
+		amount, err := parseAmount(args[1])
+		if err != nil {
+			return err
+		}
+		err = c.svc.Deposit(accountID, amount)
+		if err != nil {
+			return err
+		}
+		c.changed = true
+		account, err := c.svc.FindAccountByID(accountID)
+		if err != nil {
+			return err
+		}
+		return c.printAccount(*account)
+
+	case "pay":
+		if len(args) != 3 {
+			return usageError(command)
+		}
+		accountID, err := parseID(args[0])
+		if err != nil {
+			return err
+		}
+		amount, err := parseAmount(args[1])
+		if err != nil {
+			return err
+		}
+		payment, err := c.svc.Pay(accountID, amount, types.PaymentCategory(args[2]))
+		if err != nil {
+			return err
+		}
+		c.changed = true
+		return c.printPayment(*payment)
+
+	case "reject":
+		if len(args) != 1 {
+			return usageError(command)
+		}
+		err := c.svc.Reject(args[0])
+		if err != nil {
+			return err
+		}
+		c.changed = true
+		payment, err := c.svc.FindPaymentByID(args[0])
+		if err != nil {
+			return err
+		}
+		return c.printPayment(*payment)
+
+	case "repeat":
+		if len(args) != 1 {
+			return usageError(command)
+		}
+		payment, err := c.svc.Repeat(args[0])
+		if err != nil {
+			return err
+		}
+		c.changed = true
+		return c.printPayment(*payment)
+
+	case "favorite":
+		if len(args) != 2 {
+			return usageError(command)
+		}
+		favorite, err := c.svc.FavoritePayment(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		c.changed = true
+		return c.printFavorite(*favorite)
+
+	case "history":
+		if len(args) != 1 {
+			return usageError(command)
+		}
+		accountID, err := parseID(args[0])
+		if err != nil {
+			return err
+		}
+		payments, err := c.svc.ExportAccountHistory(accountID)
+		if err != nil {
+			return err
+		}
+		return c.printPayments(payments)
+
+	case "export":
+		if len(args) != 1 {
+			return usageError(command)
+		}
+		err := os.MkdirAll(args[0], 0777)
+		if err != nil {
+			return err
+		}
+		return c.svc.Export(args[0])
+
+	case "import":
+		if len(args) != 1 {
+			return usageError(command)
+		}
+		err := c.svc.Import(args[0])
+		if err != nil {
+			return err
+		}
+		c.changed = true
+		return nil
+
+	case "sum":
+		if len(args) > 1 {
+			return usageError(command)
+		}
+		goroutines := 0
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 {
+				return fmt.Errorf("%w: goroutines must be not negative number, 0 means number of CPUs", errUsage)
+			}
+			goroutines = n
+		}
+		sum := c.svc.SumPayments(goroutines)
+		if c.json {
+			return c.printJSON(map[string]types.Money{"sum": sum})
+		}
+		_, err := fmt.Fprintln(c.stdout, shell.FormatMoney(sum))
+		return err
+
+	case "serve":
+		if len(args) > 1 {
+			return usageError(command)
+		}
+		addr := defaultServeAddr
+		if len(args) == 1 {
+			addr = args[0]
+		}
+		return c.serve(addr)
+
+	case "rpc":
+		if len(args) != 2 || (args[0] != "tcp" && args[0] != "unix") {
+			return usageError(command)
+		}
+		return c.serveRPC(args[0], args[1])
+
+	case "shell":
+		if len(args) != 0 {
+			return usageError(command)
+		}
+		return c.shell()
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, command)
+}
+
+//serve runs HTTP server until SIGINT or SIGTERM, data is saved after shutdown
+func (c *cli) serve(addr string) error {
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "wallet listening on", listener.Addr())
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	err = server.New(c.svc).Serve(ctx, listener)
+	if err != nil {
+		return err
+	}
+	c.changed = true
+	return nil
+}
+
+//serveRPC runs JSON-RPC server until SIGINT or SIGTERM, data is saved after shutdown
+func (c *cli) serveRPC(network string, address string) error {
+
+	listener, err := rpc.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "wallet rpc listening on", network, listener.Addr())
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	err = rpc.New(c.svc).Serve(ctx, listener)
 	if err != nil {
-		fmt.Println(err)
-		//return
+		return err
+	}
+	c.changed = true
+	return nil
+}
+
+//signalContext is cancelled on SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+//shell runs interactive shell on stdin, save and load use data directory
+func (c *cli) shell() error {
+
+	terminal := false
+	info, err := os.Stdin.Stat()
+	if err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		restore, err := rawTerminal()
+		if err == nil {
+			terminal = true
+			defer restore()
+		}
+	}
+	return shell.New(c.svc, c.dir, c.stdout).Run(os.Stdin, terminal)
+}
+
+//rawTerminal turns off line buffering and echo of stdin with stty
+func rawTerminal() (restore func(), err error) {
+
+	stty := exec.Command("stty", "-g")
+	stty.Stdin = os.Stdin
+	state, err := stty.Output()
+	if err != nil {
+		return nil, err
+	}
+	stty = exec.Command("stty", "-icanon", "-echo", "min", "1")
+	stty.Stdin = os.Stdin
+	err = stty.Run()
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		stty := exec.Command("stty", strings.TrimSpace(string(state)))
+		stty.Stdin = os.Stdin
+		stty.Run()
+	}, nil
+}
+
+func usageError(command string) error {
+	return fmt.Errorf("%w: wrong arguments for %s", errUsage, command)
+}
+
+func parseID(value string) (int64, error) {
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: account id %q is not a number", errUsage, value)
+	}
+	return id, nil
+}
+
+func parseAmount(value string) (types.Money, error) {
+
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: amount %q is not a number", errUsage, value)
+	}
+	return types.Money(amount), nil
+}
+
+func (c *cli) printJSON(v interface{}) error {
+
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) printAccount(account types.Account) error {
+
+	if c.json {
+		return c.printJSON(account)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPHONE\tBALANCE\tSTATUS")
+	fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", account.ID, account.Phone, shell.FormatMoney(account.Balance), account.Status)
+	return w.Flush()
+}
+
+func (c *cli) printPayment(payment types.Payment) error {
+
+	if c.json {
+		return c.printJSON(payment)
+	}
+	return c.printPayments([]types.Payment{payment})
+}
+
+func (c *cli) printPayments(payments []types.Payment) error {
+
+	if c.json {
+		if payments == nil {
+			payments = []types.Payment{}
+		}
+		return c.printJSON(payments)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tACCOUNT\tAMOUNT\tCATEGORY\tSTATUS")
+	for _, v := range payments {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", v.ID, v.AccountID, shell.FormatMoney(v.Amount), v.Category, v.Status)
+	}
+	return w.Flush()
+}
+
+func (c *cli) printFavorite(favorite types.Favorite) error {
+
+	if c.json {
+		return c.printJSON(favorite)
 	}
-	fmt.Println(account)
-}
\ No newline at end of file
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tACCOUNT\tNAME\tAMOUNT\tCATEGORY")
+	fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", favorite.ID, favorite.AccountID, favorite.Name, shell.FormatMoney(favorite.Amount), favorite.Category)
+	return w.Flush()
+}
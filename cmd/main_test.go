@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func runCLI(t *testing.T, dir string, wantCode int, args ...string) string {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-data", dir}, args...), &stdout, &stderr)
+	if code != wantCode {
+		t.Fatalf("%v wrong exit code, want => %v got => %v, stderr => %v", args, wantCode, code, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestRun_commands_user(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var account types.Account
+	json.Unmarshal([]byte(runCLI(t, dir, exitOK, "-json", "register", "928393813")), &account)
+	if account.ID != 1 || account.Phone != "+992928393813" {
+		t.Fatalf("wrong account => %v", account)
+	}
+	json.Unmarshal([]byte(runCLI(t, dir, exitOK, "-json", "register", "928393814")), &account)
+	if account.ID != 2 {
+		t.Fatalf("id not kept between runs => %v", account)
+	}
+
+	out := runCLI(t, dir, exitOK, "deposit", "1", "10000")
+	if !strings.Contains(out, "100.00") {
+		t.Errorf("wrong deposit output => %v", out)
+	}
+
+	var payment types.Payment
+	json.Unmarshal([]byte(runCLI(t, dir, exitOK, "-json", "pay", "1", "1050", "Cafe")), &payment)
+	if payment.Amount != 1050 || payment.Status != types.PaymentStatusInProgress {
+		t.Fatalf("wrong payment => %v", payment)
+	}
+	runCLI(t, dir, exitOK, "repeat", payment.ID)
+	runCLI(t, dir, exitOK, "favorite", payment.ID, "Lunch")
+	runCLI(t, dir, exitOK, "reject", payment.ID)
+
+	var history []types.Payment
+	json.Unmarshal([]byte(runCLI(t, dir, exitOK, "-json", "history", "1")), &history)
+	if len(history) != 2 || history[0].Status != types.PaymentStatusFail {
+		t.Errorf("wrong history => %v", history)
+	}
+
+	for _, args := range [][]string{{"sum", "2"}, {"sum", "0"}, {"sum"}} {
+		out = runCLI(t, dir, exitOK, args...)
+		if strings.TrimSpace(out) != "21.00" {
+			t.Errorf("wrong sum of %v => %v", args, out)
+		}
+	}
+
+	exported, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exported)
+	runCLI(t, dir, exitOK, "export", exported)
+
+	other, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(other)
+	runCLI(t, other, exitOK, "import", exported)
+	out = runCLI(t, other, exitOK, "history", "1")
+	if !strings.Contains(out, payment.ID) {
+		t.Errorf("import lost payment => %v", out)
+	}
+}
+
+func TestRun_exitCodes_user(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	runCLI(t, dir, exitUsage)
+	runCLI(t, dir, exitUsage, "unknown")
+	runCLI(t, dir, exitUsage, "deposit", "1")
+	runCLI(t, dir, exitUsage, "deposit", "x", "1")
+	runCLI(t, dir, exitUsage, "sum", "-1")
+	runCLI(t, dir, exitInvalid, "register", "123")
+	runCLI(t, dir, exitOK, "register", "928393813")
+	runCLI(t, dir, exitConflict, "register", "928393813")
+	runCLI(t, dir, exitNotFound, "deposit", "2", "100")
+	runCLI(t, dir, exitInvalid, "deposit", "1", "-100")
+	runCLI(t, dir, exitNoBalance, "pay", "1", "100", "Cafe")
+	runCLI(t, dir, exitNotFound, "reject", "unknown")
+}
@@ -0,0 +1,211 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+//Client calls wallet HTTP API with the same signatures as wallet.Service
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+//Error is returned when API responds with error, it unwraps to wallet error with same code
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]interface{}
+}
+
+//Error method
+func (e *Error) Error() string {
+	return e.Message
+}
+
+//Unwrap returns wallet error for Code, so errors.Is works against wallet sentinels
+func (e *Error) Unwrap() error {
+	return wallet.ErrorByCode(e.Code)
+}
+
+//New creates client for baseURL like http://localhost:9999, nil httpClient means http.DefaultClient
+func New(baseURL string, httpClient *http.Client) *Client {
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: httpClient}
+}
+
+//RegisterAccount method
+func (c *Client) RegisterAccount(phone types.Phone) (*types.Account, error) {
+
+	account := &types.Account{}
+	err := c.do(http.MethodPost, "/accounts", map[string]interface{}{"phone": phone}, account)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+//FindAccountByID method
+func (c *Client) FindAccountByID(accountID int64) (*types.Account, error) {
+
+	account := &types.Account{}
+	err := c.do(http.MethodGet, accountPath(accountID, ""), nil, account)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+//Deposit method
+func (c *Client) Deposit(accountID int64, amount types.Money) error {
+	return c.do(http.MethodPost, accountPath(accountID, "/deposit"), map[string]interface{}{"amount": amount}, nil)
+}
+
+//Pay method
+func (c *Client) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+
+	payment := &types.Payment{}
+	body := map[string]interface{}{"amount": amount, "category": category}
+	err := c.do(http.MethodPost, accountPath(accountID, "/payments"), body, payment)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+//ExportAccountHistory method
+func (c *Client) ExportAccountHistory(accountID int64) ([]types.Payment, error) {
+
+	var payments []types.Payment
+	err := c.do(http.MethodGet, accountPath(accountID, "/payments"), nil, &payments)
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+//AccountFavorites method
+func (c *Client) AccountFavorites(accountID int64) ([]types.Favorite, error) {
+
+	var favorites []types.Favorite
+	err := c.do(http.MethodGet, accountPath(accountID, "/favorites"), nil, &favorites)
+	if err != nil {
+		return nil, err
+	}
+	return favorites, nil
+}
+
+//FindPaymentByID method
+func (c *Client) FindPaymentByID(paymentID string) (*types.Payment, error) {
+
+	payment := &types.Payment{}
+	err := c.do(http.MethodGet, paymentPath(paymentID, ""), nil, payment)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+//Reject method
+func (c *Client) Reject(paymentID string) error {
+	return c.do(http.MethodPost, paymentPath(paymentID, "/reject"), nil, nil)
+}
+
+//Repeat method
+func (c *Client) Repeat(paymentID string) (*types.Payment, error) {
+
+	payment := &types.Payment{}
+	err := c.do(http.MethodPost, paymentPath(paymentID, "/repeat"), nil, payment)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+//FavoritePayment method
+func (c *Client) FavoritePayment(paymentID string, name string) (*types.Favorite, error) {
+
+	favorite := &types.Favorite{}
+	err := c.do(http.MethodPost, paymentPath(paymentID, "/favorite"), map[string]interface{}{"name": name}, favorite)
+	if err != nil {
+		return nil, err
+	}
+	return favorite, nil
+}
+
+//PayFromFavorite method
+func (c *Client) PayFromFavorite(favoriteID string) (*types.Payment, error) {
+
+	payment := &types.Payment{}
+	err := c.do(http.MethodPost, "/favorites/"+url.PathEscape(favoriteID)+"/pay", nil, payment)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func accountPath(accountID int64, action string) string {
+	return "/accounts/" + strconv.FormatInt(accountID, 10) + action
+}
+
+func paymentPath(paymentID string, action string) string {
+	return "/payments/" + url.PathEscape(paymentID) + action
+}
+
+func (c *Client) do(method string, path string, body interface{}, result interface{}) error {
+
+	var reader bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&reader).Encode(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, &reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{Status: resp.StatusCode}
+		var data struct {
+			Error   string                 `json:"error"`
+			Code    string                 `json:"code"`
+			Details map[string]interface{} `json:"details"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&data)
+		if err != nil {
+			apiErr.Message = resp.Status
+			return apiErr
+		}
+		apiErr.Code = data.Code
+		apiErr.Message = data.Error
+		apiErr.Details = data.Details
+		return apiErr
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/server"
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+func TestClient_sameAsService_user(t *testing.T) {
+	svc := &wallet.Service{}
+	ts := httptest.NewServer(server.New(svc))
+	defer ts.Close()
+	c := New(ts.URL, ts.Client())
+
+	account, err := c.RegisterAccount("+992928393813")
+	if err != nil {
+		t.Fatalf("method RegisterAccount returned not nil error, error => %v", err)
+	}
+	err = c.Deposit(account.ID, 100_00)
+	if err != nil {
+		t.Fatalf("method Deposit returned not nil error, error => %v", err)
+	}
+	payment, err := c.Pay(account.ID, 10_00, "Cafe")
+	if err != nil {
+		t.Fatalf("method Pay returned not nil error, error => %v", err)
+	}
+	_, err = c.Repeat(payment.ID)
+	if err != nil {
+		t.Fatalf("method Repeat returned not nil error, error => %v", err)
+	}
+	favorite, err := c.FavoritePayment(payment.ID, "Lunch")
+	if err != nil {
+		t.Fatalf("method FavoritePayment returned not nil error, error => %v", err)
+	}
+	_, err = c.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Fatalf("method PayFromFavorite returned not nil error, error => %v", err)
+	}
+	err = c.Reject(payment.ID)
+	if err != nil {
+		t.Fatalf("method Reject returned not nil error, error => %v", err)
+	}
+
+	remote, err := c.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Fatalf("method FindPaymentByID returned not nil error, error => %v", err)
+	}
+	local, _ := svc.FindPaymentByID(payment.ID)
+	if !reflect.DeepEqual(*remote, *local) {
+		t.Errorf("wrong payment, want => %v got => %v", *local, *remote)
+	}
+
+	history, err := c.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Fatalf("method ExportAccountHistory returned not nil error, error => %v", err)
+	}
+	want, _ := svc.ExportAccountHistory(account.ID)
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("wrong history, want => %v got => %v", want, history)
+	}
+
+	favorites, err := c.AccountFavorites(account.ID)
+	if err != nil || len(favorites) != 1 {
+		t.Errorf("wrong favorites => %v, error => %v", favorites, err)
+	}
+
+	remoteAccount, err := c.FindAccountByID(account.ID)
+	if err != nil || remoteAccount.Balance != 80_00 {
+		t.Errorf("wrong account => %v, error => %v", remoteAccount, err)
+	}
+}
+
+func TestClient_errors_user(t *testing.T) {
+	ts := httptest.NewServer(server.New(&wallet.Service{}))
+	defer ts.Close()
+	c := New(ts.URL, nil)
+
+	_, err := c.FindAccountByID(1)
+	if !errors.Is(err, wallet.ErrAccountNotFound) {
+		t.Errorf("method FindAccountByID returned wrong error, error => %v", err)
+	}
+	_, err = c.RegisterAccount("123")
+	if !errors.Is(err, wallet.ErrPhoneInvalid) {
+		t.Errorf("method RegisterAccount returned wrong error, error => %v", err)
+	}
+	account, _ := c.RegisterAccount("+992928393813")
+	_, err = c.Pay(account.ID, 1, types.PaymentCategory("Cafe"))
+	if !errors.Is(err, wallet.ErrNotEnoughtBalance) {
+		t.Errorf("method Pay returned wrong error, error => %v", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != 422 || apiErr.Code != "not_enough_balance" {
+		t.Errorf("wrong api error => %v", apiErr)
+	}
+	if apiErr.Details["account_id"] != float64(account.ID) || apiErr.Details["missing"] != float64(1) {
+		t.Errorf("wrong error details => %v", apiErr.Details)
+	}
+	err = c.Reject("unknown")
+	if !errors.Is(err, wallet.ErrPaymentNotFound) {
+		t.Errorf("method Reject returned wrong error, error => %v", err)
+	}
+}
+
+//recorder answers every request with null and keeps method and path of last one
+type recorder struct {
+	method string
+	path   string
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.method = req.Method
+	r.path = req.URL.Path
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("null")), Request: req}, nil
+}
+
+//specOperations returns method and path pattern of every operation of OpenAPI by operationId
+func specOperations(t *testing.T) map[string][2]string {
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal([]byte(server.OpenAPI), &spec)
+	if err != nil {
+		t.Fatalf("OpenAPI is not valid json => %v", err)
+	}
+	operations := make(map[string][2]string)
+	for path, methods := range spec.Paths {
+		for method, raw := range methods {
+			var v struct {
+				OperationID string `json:"operationId"`
+			}
+			if method == "parameters" || json.Unmarshal(raw, &v) != nil || v.OperationID == "" {
+				continue
+			}
+			pattern := "^" + regexp.MustCompile(`\{[^}]+\}`).ReplaceAllString(path, "[^/]+") + "$"
+			operations[v.OperationID] = [2]string{strings.ToUpper(method), pattern}
+		}
+	}
+	return operations
+}
+
+func TestClient_matchesOpenAPI_user(t *testing.T) {
+	operations := specOperations(t)
+	rec := &recorder{}
+	c := New("http://wallet", &http.Client{Transport: rec})
+
+	// operations which are not plain calls have no method in client
+	notInClient := map[string]bool{"AccountEvents": true, "OpenAPI": true}
+	value := reflect.ValueOf(c)
+	for i := 0; i < value.NumMethod(); i++ {
+		name := value.Type().Method(i).Name
+		operation, ok := operations[name]
+		if !ok {
+			t.Errorf("method %s is not described in OpenAPI", name)
+			continue
+		}
+		delete(operations, name)
+
+		method := value.Method(i)
+		var args []reflect.Value
+		for j := 0; j < method.Type().NumIn(); j++ {
+			arg := reflect.New(method.Type().In(j)).Elem()
+			switch arg.Kind() {
+			case reflect.Int64:
+				arg.SetInt(1)
+			case reflect.String:
+				arg.SetString("x")
+			}
+			args = append(args, arg)
+		}
+		method.Call(args)
+		if rec.method != operation[0] || !regexp.MustCompile(operation[1]).MatchString(rec.path) {
+			t.Errorf("method %s calls %s %s, OpenAPI describes %s %s", name, rec.method, rec.path, operation[0], operation[1])
+		}
+	}
+	for name := range operations {
+		if !notInClient[name] {
+			t.Errorf("operation %s has no method in client", name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"errors"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+//JSON-RPC 2.0 protocol error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+//Error is JSON-RPC error object, for wallet errors Code is stable code of sentinel
+type Error struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *ErrorData `json:"data,omitempty"`
+}
+
+//ErrorData describes wallet error
+type ErrorData struct {
+	Code    string                 `json:"code"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+//Error method
+func (e *Error) Error() string {
+	return e.Message
+}
+
+//Unwrap returns wallet error with same code, so errors.Is works against wallet sentinels
+func (e *Error) Unwrap() error {
+	return wallet.ErrorByRPCCode(e.Code)
+}
+
+//ErrorCode returns stable code of wallet error, CodeInternalError for unknown errors
+func ErrorCode(err error) int {
+
+	code := wallet.RPCCode(err)
+	if code == 0 {
+		return CodeInternalError
+	}
+	return code
+}
+
+func toError(err error) *Error {
+
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	code := wallet.RPCCode(err)
+	if code == 0 {
+		return &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	return &Error{
+		Code:    code,
+		Message: err.Error(),
+		Data:    &ErrorData{Code: wallet.ErrorCode(err), Details: wallet.ErrorDetails(err)},
+	}
+}
@@ -0,0 +1,442 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+const version = "2.0"
+
+//Server serves wallet Service over JSON-RPC 2.0, one JSON value per request or batch
+type Server struct {
+	mu  sync.Locker
+	svc *wallet.Service
+}
+
+type request struct {
+	method string
+	params json.RawMessage
+	id     json.RawMessage
+	notify bool
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *Error           `json:"error,omitempty"`
+	ID      json.RawMessage  `json:"id"`
+}
+
+//New creates server, every call of svc holds svc.Locker()
+func New(svc *wallet.Service) *Server {
+	return &Server{mu: svc.Locker(), svc: svc}
+}
+
+//Listen listens on network tcp or unix, stale unix socket file is removed
+func Listen(network string, address string) (net.Listener, error) {
+
+	if network == "unix" {
+		info, err := os.Stat(address)
+		if err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	return net.Listen(network, address)
+}
+
+//Serve accepts connections until ctx is done, then waits for requests in progress
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+			return
+		}
+		listener.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.SetReadDeadline(time.Now())
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(stopped)
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = true
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.ServeConn(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+}
+
+//ServeConn reads requests from conn until it is closed
+func (s *Server) ServeConn(conn net.Conn) {
+
+	defer conn.Close()
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	writer := bufio.NewWriter(conn)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				writeJSON(writer, errorResponse(nil, &Error{Code: CodeParseError, Message: "parse error"}))
+			}
+			return
+		}
+
+		result := s.Handle(raw)
+		if result == nil {
+			continue
+		}
+		err = writeJSON(writer, result)
+		if err != nil {
+			return
+		}
+	}
+}
+
+//Handle executes single request or batch and returns response, nil when there is nothing to answer
+func (s *Server) Handle(raw json.RawMessage) interface{} {
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '[' {
+		resp := s.handleOne(raw)
+		if resp == nil {
+			return nil
+		}
+		return resp
+	}
+
+	var batch []json.RawMessage
+	err := json.Unmarshal(raw, &batch)
+	if err != nil {
+		return errorResponse(nil, &Error{Code: CodeParseError, Message: "parse error"})
+	}
+	if len(batch) == 0 {
+		return errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "empty batch"})
+	}
+
+	var responses []*response
+	for _, v := range batch {
+		resp := s.handleOne(v)
+		if resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+func (s *Server) handleOne(raw json.RawMessage) *response {
+
+	req, rpcErr := parseRequest(raw)
+	if rpcErr != nil {
+		return errorResponse(req.id, rpcErr)
+	}
+
+	result, err := s.call(req.method, req.params)
+	if req.notify {
+		return nil
+	}
+	if err != nil {
+		return errorResponse(req.id, toError(err))
+	}
+	return &response{JSONRPC: version, Result: &result, ID: req.id}
+}
+
+func parseRequest(raw json.RawMessage) (request, *Error) {
+
+	var req request
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(raw, &fields)
+	if err != nil || fields == nil {
+		return req, &Error{Code: CodeInvalidRequest, Message: "request must be object"}
+	}
+
+	id, ok := fields["id"]
+	req.notify = !ok
+	if ok {
+		switch bytes.TrimSpace(id)[0] {
+		case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			req.id = id
+		default:
+			return req, &Error{Code: CodeInvalidRequest, Message: "id must be string, number or null"}
+		}
+	}
+
+	var jsonrpc string
+	if json.Unmarshal(fields["jsonrpc"], &jsonrpc) != nil || jsonrpc != version {
+		return req, &Error{Code: CodeInvalidRequest, Message: "jsonrpc must be 2.0"}
+	}
+	if json.Unmarshal(fields["method"], &req.method) != nil || req.method == "" {
+		return req, &Error{Code: CodeInvalidRequest, Message: "method must be string"}
+	}
+	req.params = fields["params"]
+	return req, nil
+}
+
+func errorResponse(id json.RawMessage, err *Error) *response {
+
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: version, Error: err, ID: id}
+}
+
+func writeJSON(writer *bufio.Writer, v interface{}) error {
+
+	err := json.NewEncoder(writer).Encode(v)
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+//call marshals result while lock is held, so values owned by service are not read after unlock
+func (s *Server) call(name string, params json.RawMessage) (json.RawMessage, error) {
+
+	m, ok := methods[name]
+	if !ok {
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method " + name + " not found"}
+	}
+	a, err := newArgs(params, m.params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, err := m.call(s.svc, a)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	return data, nil
+}
+
+type method struct {
+	params []string
+	call   func(svc *wallet.Service, a *args) (interface{}, error)
+}
+
+//methods are named as Service methods with wallet. prefix, params are positional or by name
+var methods = map[string]method{
+	"wallet.RegisterAccount": {[]string{"phone"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		phone := a.string(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return svc.RegisterAccount(types.Phone(phone))
+	}},
+	"wallet.FindAccountByID": {[]string{"accountID"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		accountID := a.int64(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return svc.FindAccountByID(accountID)
+	}},
+	"wallet.Accounts": {nil, func(svc *wallet.Service, a *args) (interface{}, error) {
+		accounts := svc.Accounts()
+		if accounts == nil {
+			accounts = []types.Account{}
+		}
+		return accounts, nil
+	}},
+	"wallet.Deposit": {[]string{"accountID", "amount"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		accountID, amount := a.int64(0), a.int64(1)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return nil, svc.Deposit(accountID, types.Money(amount))
+	}},
+	"wallet.Pay": {[]string{"accountID", "amount", "category"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		accountID, amount, category := a.int64(0), a.int64(1), a.string(2)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return svc.Pay(accountID, types.Money(amount), types.PaymentCategory(category))
+	}},
+	"wallet.FindPaymentByID": {[]string{"paymentID"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		paymentID := a.string(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return svc.FindPaymentByID(paymentID)
+	}},
+	"wallet.Reject": {[]string{"paymentID"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		paymentID := a.string(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return nil, svc.Reject(paymentID)
+	}},
+	"wallet.Repeat": {[]string{"paymentID"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		paymentID := a.string(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return svc.Repeat(paymentID)
+	}},
+	"wallet.FavoritePayment": {[]string{"paymentID", "name"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		paymentID, name := a.string(0), a.string(1)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return svc.FavoritePayment(paymentID, name)
+	}},
+	"wallet.PayFromFavorite": {[]string{"favoriteID"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		favoriteID := a.string(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return svc.PayFromFavorite(favoriteID)
+	}},
+	"wallet.AccountFavorites": {[]string{"accountID"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		accountID := a.int64(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		favorites, err := svc.AccountFavorites(accountID)
+		if err != nil {
+			return nil, err
+		}
+		if favorites == nil {
+			favorites = []types.Favorite{}
+		}
+		return favorites, nil
+	}},
+	"wallet.ExportAccountHistory": {[]string{"accountID"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		accountID := a.int64(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		payments, err := svc.ExportAccountHistory(accountID)
+		if err != nil {
+			return nil, err
+		}
+		if payments == nil {
+			payments = []types.Payment{}
+		}
+		return payments, nil
+	}},
+	"wallet.SumPayments": {[]string{"goroutines"}, func(svc *wallet.Service, a *args) (interface{}, error) {
+		goroutines := a.int64(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		// 0 means runtime.NumCPU(), as in wallet.Service.SumPayments
+		if goroutines < 0 {
+			return nil, &Error{Code: CodeInvalidParams, Message: "goroutines must not be negative"}
+		}
+		return svc.SumPayments(int(goroutines)), nil
+	}},
+}
+
+//args holds positional params, first decode error is kept in err
+type args struct {
+	values []json.RawMessage
+	err    error
+}
+
+func newArgs(params json.RawMessage, names []string) (*args, error) {
+
+	invalid := &Error{Code: CodeInvalidParams, Message: "invalid params"}
+	params = bytes.TrimSpace(params)
+	a := &args{}
+
+	switch {
+	case len(params) == 0 || bytes.Equal(params, []byte("null")):
+	case params[0] == '[':
+		err := json.Unmarshal(params, &a.values)
+		if err != nil {
+			return nil, invalid
+		}
+	case params[0] == '{':
+		var named map[string]json.RawMessage
+		err := json.Unmarshal(params, &named)
+		if err != nil {
+			return nil, invalid
+		}
+		for _, name := range names {
+			value, ok := named[name]
+			if !ok {
+				break
+			}
+			a.values = append(a.values, value)
+		}
+		if len(a.values) != len(named) {
+			return nil, &Error{Code: CodeInvalidParams, Message: "unknown or missing named params"}
+		}
+	default:
+		return nil, invalid
+	}
+
+	if len(a.values) != len(names) {
+		return nil, &Error{Code: CodeInvalidParams, Message: "wrong number of params"}
+	}
+	return a, nil
+}
+
+func (a *args) decode(i int, v interface{}) {
+
+	if a.err != nil {
+		return
+	}
+	err := json.Unmarshal(a.values[i], v)
+	if err != nil {
+		a.err = &Error{Code: CodeInvalidParams, Message: "invalid param " + err.Error()}
+	}
+}
+
+func (a *args) int64(i int) int64 {
+
+	var v int64
+	a.decode(i, &v)
+	return v
+}
+
+func (a *args) string(i int) string {
+
+	var v string
+	a.decode(i, &v)
+	return v
+}
@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+type testResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+func handle(t *testing.T, s *Server, request string) string {
+	t.Helper()
+
+	result := s.Handle(json.RawMessage(request))
+	if result == nil {
+		return ""
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestServer_Handle_methods_user(t *testing.T) {
+	s := New(&wallet.Service{})
+
+	got := handle(t, s, `{"jsonrpc":"2.0","method":"wallet.RegisterAccount","params":["928393813"],"id":1}`)
+	want := `{"jsonrpc":"2.0","result":{"id":1,"phone":"+992928393813","balance":0,"status":"ACTIVE"},"id":1}`
+	if got != want {
+		t.Errorf("wrong response, want => %v got => %v", want, got)
+	}
+
+	got = handle(t, s, `{"jsonrpc":"2.0","method":"wallet.Deposit","params":{"accountID":1,"amount":100},"id":"a"}`)
+	if got != `{"jsonrpc":"2.0","result":null,"id":"a"}` {
+		t.Errorf("wrong deposit response => %v", got)
+	}
+
+	got = handle(t, s, `{"jsonrpc":"2.0","method":"wallet.Pay","params":[1,1000,"Cafe"],"id":2}`)
+	var resp testResponse
+	json.Unmarshal([]byte(got), &resp)
+	if resp.Error == nil || resp.Error.Code != 1004 || resp.Error.Data.Code != "not_enough_balance" {
+		t.Fatalf("wrong error response => %v", got)
+	}
+	if resp.Error.Data.Details["balance"] != float64(100) || resp.Error.Data.Details["amount"] != float64(1000) {
+		t.Errorf("wrong error details => %v", resp.Error.Data.Details)
+	}
+	if !errors.Is(resp.Error, wallet.ErrNotEnoughtBalance) {
+		t.Errorf("error does not unwrap to sentinel => %v", resp.Error)
+	}
+
+	handle(t, s, `{"jsonrpc":"2.0","method":"wallet.Pay","params":[1,40,"Cafe"],"id":3}`)
+	for _, params := range []string{"[0]", "[3]"} {
+		got = handle(t, s, `{"jsonrpc":"2.0","method":"wallet.SumPayments","params":`+params+`,"id":4}`)
+		if got != `{"jsonrpc":"2.0","result":40,"id":4}` {
+			t.Errorf("wrong sum response for %v => %v", params, got)
+		}
+	}
+}
+
+func TestServer_Handle_protocolErrors_user(t *testing.T) {
+	s := New(&wallet.Service{})
+
+	tests := map[string]int{
+		`{"jsonrpc":"2.0","method":"wallet.Unknown","id":1}`:                  CodeMethodNotFound,
+		`{"jsonrpc":"2.0","method":"wallet.Pay","params":[1],"id":1}`:         CodeInvalidParams,
+		`{"jsonrpc":"2.0","method":"wallet.Pay","params":["1",1,"x"],"id":1}`: CodeInvalidParams,
+		`{"jsonrpc":"2.0","method":"wallet.Deposit","params":{"x":1},"id":1}`: CodeInvalidParams,
+		`{"jsonrpc":"1.0","method":"wallet.Accounts","id":1}`:                 CodeInvalidRequest,
+		`{"jsonrpc":"2.0","method":1,"id":1}`:                                 CodeInvalidRequest,
+		`{"jsonrpc":"2.0","method":"wallet.Accounts","id":{}}`:                CodeInvalidRequest,
+		`1`:  CodeInvalidRequest,
+		`[]`: CodeInvalidRequest,
+		`{"jsonrpc":"2.0","method":"wallet.SumPayments","params":[-1],"id":1}`:    CodeInvalidParams,
+		`{"jsonrpc":"2.0","method":"wallet.FindAccountByID","params":[1],"id":1}`: 1003,
+	}
+	for request, code := range tests {
+		var resp testResponse
+		json.Unmarshal([]byte(handle(t, s, request)), &resp)
+		if resp.Error == nil || resp.Error.Code != code {
+			t.Errorf("%v wrong error, want => %v got => %v", request, code, resp.Error)
+		}
+	}
+}
+
+func TestServer_Handle_batchAndNotifications_user(t *testing.T) {
+	s := New(&wallet.Service{})
+
+	got := handle(t, s, `{"jsonrpc":"2.0","method":"wallet.RegisterAccount","params":["928393813"]}`)
+	if got != "" {
+		t.Errorf("notification answered => %v", got)
+	}
+	got = handle(t, s, `[{"jsonrpc":"2.0","method":"wallet.Deposit","params":[1,100]},{"jsonrpc":"2.0","method":"wallet.Deposit","params":[9,100]}]`)
+	if got != "" {
+		t.Errorf("batch of notifications answered => %v", got)
+	}
+
+	var responses []testResponse
+	got = handle(t, s, `[
+		{"jsonrpc":"2.0","method":"wallet.FindAccountByID","params":[1],"id":1},
+		{"jsonrpc":"2.0","method":"wallet.Deposit","params":[1,100]},
+		{"foo":"bar"},
+		{"jsonrpc":"2.0","method":"wallet.FindAccountByID","params":[2],"id":2}
+	]`)
+	json.Unmarshal([]byte(got), &responses)
+	if len(responses) != 3 {
+		t.Fatalf("wrong responses => %v", got)
+	}
+	if !strings.Contains(string(responses[0].Result), `"balance":100`) {
+		t.Errorf("wrong first response => %v", string(responses[0].Result))
+	}
+	if responses[1].Error.Code != CodeInvalidRequest || responses[2].Error.Code != 1003 {
+		t.Errorf("wrong error responses => %v", got)
+	}
+}
+
+func TestServer_Serve_tcpAndUnix_user(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, addr := range [][2]string{{"tcp", "127.0.0.1:0"}, {"unix", filepath.Join(dir, "wallet.sock")}} {
+		listener, err := Listen(addr[0], addr[1])
+		if err != nil {
+			t.Fatalf("%v listen error => %v", addr[0], err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- New(&wallet.Service{}).Serve(ctx, listener)
+		}()
+
+		conn, err := net.Dial(addr[0], listener.Addr().String())
+		if err != nil {
+			t.Fatalf("%v dial error => %v", addr[0], err)
+		}
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte(`{"jsonrpc":"2.0","method":"wallet.RegisterAccount","params":["928393813"],"id":1}` + "\n"))
+		conn.Write([]byte(`{"jsonrpc":"2.0","method":"wallet.Deposit","params":[1,5]}{"jsonrpc":"2.0","method":"wallet.Accounts","id":2}`))
+		for id := 1; id <= 2; id++ {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("%v read error => %v", addr[0], err)
+			}
+			if !strings.Contains(line, `"id":`+string(rune('0'+id))) {
+				t.Errorf("%v wrong response => %v", addr[0], line)
+			}
+		}
+		conn.Write([]byte("{oops"))
+		line, _ := reader.ReadString('\n')
+		if !strings.Contains(line, "-32700") {
+			t.Errorf("%v wrong parse error => %v", addr[0], line)
+		}
+
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%v method Serve returned not nil error, error => %v", addr[0], err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v server not stopped", addr[0])
+		}
+		conn.Close()
+	}
+}
+
+func TestServer_Handle_parallel_user(t *testing.T) {
+	s := New(&wallet.Service{})
+	handle(t, s, `{"jsonrpc":"2.0","method":"wallet.RegisterAccount","params":["928393813"],"id":1}`)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.Handle(json.RawMessage(`{"jsonrpc":"2.0","method":"wallet.Deposit","params":[1,1],"id":1}`))
+		}()
+		go func() {
+			defer wg.Done()
+			s.Handle(json.RawMessage(`{"jsonrpc":"2.0","method":"wallet.FindAccountByID","params":[1],"id":1}`))
+		}()
+	}
+	wg.Wait()
+
+	got := handle(t, s, `{"jsonrpc":"2.0","method":"wallet.FindAccountByID","params":[1],"id":1}`)
+	if !strings.Contains(got, `"balance":100`) {
+		t.Errorf("wrong account => %v", got)
+	}
+}
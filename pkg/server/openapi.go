@@ -0,0 +1,300 @@
+package server
+
+import "net/http"
+
+//APIVersion is version of HTTP API described by OpenAPI
+const APIVersion = "1.0.0"
+
+//OpenAPI is OpenAPI 3 document of HTTP API
+const OpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Wallet API",
+    "version": "` + APIVersion + `"
+  },
+  "paths": {
+    "/accounts": {
+      "post": {
+        "operationId": "RegisterAccount",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PhoneRequest"}}}},
+        "responses": {
+          "201": {"$ref": "#/components/responses/Account"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/accounts/{accountID}": {
+      "parameters": [{"$ref": "#/components/parameters/AccountID"}],
+      "get": {
+        "operationId": "FindAccountByID",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Account"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/accounts/{accountID}/deposit": {
+      "parameters": [{"$ref": "#/components/parameters/AccountID"}],
+      "post": {
+        "operationId": "Deposit",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AmountRequest"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Account"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/accounts/{accountID}/payments": {
+      "parameters": [{"$ref": "#/components/parameters/AccountID"}],
+      "get": {
+        "operationId": "ExportAccountHistory",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Payments"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "Pay",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PayRequest"}}}},
+        "responses": {
+          "201": {"$ref": "#/components/responses/Payment"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"},
+          "423": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/accounts/{accountID}/favorites": {
+      "parameters": [{"$ref": "#/components/parameters/AccountID"}],
+      "get": {
+        "operationId": "AccountFavorites",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Favorites"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/accounts/{accountID}/events": {
+      "parameters": [{"$ref": "#/components/parameters/AccountID"}],
+      "get": {
+        "operationId": "AccountEvents",
+        "description": "Server-sent events of account: payment.created, payment.confirmed, payment.rejected, account.deposited, account.status_changed, account.points_redeemed and data.imported of all accounts. SSE id is event seq, send it back as Last-Event-ID header or last_event_id query to resume. Every account keeps last 1000 events, when events after cursor were dropped event gap without id is sent first with data {\"from\": seq, \"to\": seq} of possibly lost range.",
+        "parameters": [
+          {"name": "Last-Event-ID", "in": "header", "schema": {"type": "integer", "format": "int64"}},
+          {"name": "last_event_id", "in": "query", "schema": {"type": "integer", "format": "int64"}}
+        ],
+        "responses": {
+          "200": {"description": "event stream", "content": {"text/event-stream": {"schema": {"$ref": "#/components/schemas/AccountEvent"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/payments/{paymentID}": {
+      "parameters": [{"$ref": "#/components/parameters/PaymentID"}],
+      "get": {
+        "operationId": "FindPaymentByID",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Payment"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/payments/{paymentID}/reject": {
+      "parameters": [{"$ref": "#/components/parameters/PaymentID"}],
+      "post": {
+        "operationId": "Reject",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Payment"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/payments/{paymentID}/repeat": {
+      "parameters": [{"$ref": "#/components/parameters/PaymentID"}],
+      "post": {
+        "operationId": "Repeat",
+        "responses": {
+          "201": {"$ref": "#/components/responses/Payment"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"},
+          "423": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/payments/{paymentID}/favorite": {
+      "parameters": [{"$ref": "#/components/parameters/PaymentID"}],
+      "post": {
+        "operationId": "FavoritePayment",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NameRequest"}}}},
+        "responses": {
+          "201": {"$ref": "#/components/responses/Favorite"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/favorites/{favoriteID}/pay": {
+      "parameters": [{"name": "favoriteID", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "post": {
+        "operationId": "PayFromFavorite",
+        "responses": {
+          "201": {"$ref": "#/components/responses/Payment"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"},
+          "423": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "OpenAPI",
+        "responses": {"200": {"description": "this document"}}
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "AccountID": {"name": "accountID", "in": "path", "required": true, "schema": {"type": "integer", "format": "int64"}},
+      "PaymentID": {"name": "paymentID", "in": "path", "required": true, "schema": {"type": "string"}}
+    },
+    "schemas": {
+      "Account": {
+        "type": "object",
+        "required": ["id", "phone", "balance", "status"],
+        "properties": {
+          "id": {"type": "integer", "format": "int64"},
+          "phone": {"type": "string", "example": "+992928393813"},
+          "balance": {"type": "integer", "format": "int64"},
+          "status": {"type": "string", "enum": ["ACTIVE", "FROZEN", "CLOSED"]}
+        }
+      },
+      "Payment": {
+        "type": "object",
+        "required": ["id", "account_id", "amount", "category", "status"],
+        "properties": {
+          "id": {"type": "string"},
+          "account_id": {"type": "integer", "format": "int64"},
+          "amount": {"type": "integer", "format": "int64"},
+          "category": {"type": "string"},
+          "status": {"type": "string", "enum": ["OK", "FAIL", "INPROGRESS"]}
+        }
+      },
+      "Favorite": {
+        "type": "object",
+        "required": ["id", "account_id", "name", "amount", "category"],
+        "properties": {
+          "id": {"type": "string"},
+          "account_id": {"type": "integer", "format": "int64"},
+          "name": {"type": "string"},
+          "amount": {"type": "integer", "format": "int64"},
+          "category": {"type": "string"}
+        }
+      },
+      "AccountEvent": {
+        "type": "object",
+        "required": ["id", "seq", "type", "at", "balance"],
+        "properties": {
+          "id": {"type": "string"},
+          "seq": {"type": "integer", "format": "int64"},
+          "type": {"type": "string", "enum": ["payment.created", "payment.confirmed", "payment.rejected", "account.deposited", "account.status_changed", "account.points_redeemed", "data.imported"]},
+          "at": {"type": "string", "format": "date-time"},
+          "amount": {"type": "integer", "format": "int64"},
+          "balance": {"type": "integer", "format": "int64"},
+          "status": {"type": "string", "enum": ["ACTIVE", "FROZEN", "CLOSED"]},
+          "payment": {"$ref": "#/components/schemas/Payment"}
+        }
+      },
+      "PhoneRequest": {
+        "type": "object",
+        "required": ["phone"],
+        "properties": {"phone": {"type": "string"}}
+      },
+      "AmountRequest": {
+        "type": "object",
+        "required": ["amount"],
+        "properties": {"amount": {"type": "integer", "format": "int64"}}
+      },
+      "PayRequest": {
+        "type": "object",
+        "required": ["amount", "category"],
+        "properties": {
+          "amount": {"type": "integer", "format": "int64"},
+          "category": {"type": "string"}
+        }
+      },
+      "NameRequest": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {"name": {"type": "string"}}
+      },
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {
+          "error": {"type": "string"},
+          "code": {
+            "type": "string",
+            "enum": [
+              "phone_registered", "amount_must_be_positive", "account_not_found", "not_enough_balance",
+              "payment_not_found", "favorite_not_found", "phone_invalid", "favorite_name_exists",
+              "favorite_name_invalid", "account_frozen", "account_closed", "account_has_balance",
+              "reward_rule_invalid", "payment_not_in_progress", "not_enough_points", "schedule_not_found",
+              "schedule_invalid", "schedule_not_active", "batch_aborted", "batch_mode_invalid",
+              "money_request_not_found", "money_request_invalid", "money_request_expired", "money_request_not_pending",
+              "code_not_found", "code_invalid", "code_expired", "code_attempts",
+              "verification_required", "account_not_verified", "account_verified", "pin_invalid",
+              "pin_already_set", "pin_not_set", "auth_failed", "account_locked",
+              "session_not_found", "session_expired", "forbidden", "audit_tampered",
+              "webhook_not_found", "webhook_invalid", "dump_invalid", "payment_not_rejectable"
+            ]
+          },
+          "details": {
+            "type": "object",
+            "properties": {
+              "account_id": {"type": "integer", "format": "int64"},
+              "phone": {"type": "string"},
+              "payment_id": {"type": "string"},
+              "favorite_id": {"type": "string"},
+              "amount": {"type": "integer", "format": "int64", "description": "requested amount"},
+              "balance": {"type": "integer", "format": "int64", "description": "available balance"},
+              "missing": {"type": "integer", "format": "int64", "description": "amount minus balance"}
+            }
+          }
+        }
+      }
+    },
+    "responses": {
+      "Account": {"description": "account", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Account"}}}},
+      "Payment": {"description": "payment", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Payment"}}}},
+      "Favorite": {"description": "favorite", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Favorite"}}}},
+      "Payments": {"description": "payments", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Payment"}}}}},
+      "Favorites": {"description": "favorites", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Favorite"}}}}},
+      "Error": {"description": "error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    }
+  }
+}
+`
+
+//handleOpenAPI serves GET /openapi.json
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(OpenAPI))
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+//Server serves wallet Service over JSON HTTP
+type Server struct {
+	mu     sync.Locker
+	svc    *wallet.Service
+	mux    *http.ServeMux
+	stream *stream
+}
+
+//New creates server, every call of svc holds svc.Locker()
+func New(svc *wallet.Service) *Server {
+
+	s := &Server{mu: svc.Locker(), svc: svc, mux: http.NewServeMux(), stream: newStream()}
+	svc.Subscribe(s.stream.publish, wallet.DeliverSync)
+	s.mux.HandleFunc("/accounts", s.handleAccounts)
+	s.mux.HandleFunc("/accounts/", s.handleAccount)
+	s.mux.HandleFunc("/payments/", s.handlePayment)
+	s.mux.HandleFunc("/favorites/", s.handleFavorite)
+	s.mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	return s
+}
+
+//ServeHTTP method
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+//Serve serves on listener until ctx is done, then shuts down gracefully
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+
+	srv := &http.Server{Handler: s}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.stream.close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+	err = <-errs
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+type phoneRequest struct {
+	Phone types.Phone `json:"phone"`
+}
+
+type amountRequest struct {
+	Amount types.Money `json:"amount"`
+}
+
+type payRequest struct {
+	Amount   types.Money           `json:"amount"`
+	Category types.PaymentCategory `json:"category"`
+}
+
+type nameRequest struct {
+	Name string `json:"name"`
+}
+
+type errorResponse struct {
+	Error   string                 `json:"error"`
+	Code    string                 `json:"code,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+//handleAccounts serves POST /accounts
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	var req phoneRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	account, err := s.svc.RegisterAccount(req.Phone)
+	result := accountValue(account)
+	s.mu.Unlock()
+	writeResult(w, http.StatusCreated, result, err)
+}
+
+//handleAccount serves GET /accounts/{id}, POST /accounts/{id}/deposit,
+//GET and POST /accounts/{id}/payments, GET /accounts/{id}/favorites, GET /accounts/{id}/events
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+
+	parts := splitPath(r.URL.Path, "/accounts/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, wallet.ErrAccountNotFound)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		account, err := s.svc.FindAccountByID(id)
+		result := accountValue(account)
+		s.mu.Unlock()
+		writeResult(w, http.StatusOK, result, err)
+
+	case action == "deposit" && r.Method == http.MethodPost:
+		var req amountRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		s.mu.Lock()
+		err := s.svc.Deposit(id, req.Amount)
+		var account *types.Account
+		if err == nil {
+			account, err = s.svc.FindAccountByID(id)
+		}
+		result := accountValue(account)
+		s.mu.Unlock()
+		writeResult(w, http.StatusOK, result, err)
+
+	case action == "payments" && r.Method == http.MethodPost:
+		var req payRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		s.mu.Lock()
+		payment, err := s.svc.Pay(id, req.Amount, req.Category)
+		result := paymentValue(payment)
+		s.mu.Unlock()
+		writeResult(w, http.StatusCreated, result, err)
+
+	case action == "payments" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		payments, err := s.svc.ExportAccountHistory(id)
+		s.mu.Unlock()
+		if payments == nil {
+			payments = []types.Payment{}
+		}
+		writeResult(w, http.StatusOK, payments, err)
+
+	case action == "favorites" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		favorites, err := s.svc.AccountFavorites(id)
+		s.mu.Unlock()
+		if favorites == nil {
+			favorites = []types.Favorite{}
+		}
+		writeResult(w, http.StatusOK, favorites, err)
+
+	case action == "events" && r.Method == http.MethodGet:
+		s.handleEvents(w, r, id)
+
+	case action == "" || action == "deposit" || action == "payments" || action == "favorites" || action == "events":
+		writeMethodNotAllowed(w)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+//handlePayment serves GET /payments/{id}, POST /payments/{id}/reject,
+//POST /payments/{id}/repeat, POST /payments/{id}/favorite
+func (s *Server) handlePayment(w http.ResponseWriter, r *http.Request) {
+
+	parts := splitPath(r.URL.Path, "/payments/")
+	id := parts[0]
+	if id == "" || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, wallet.ErrPaymentNotFound)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		payment, err := s.svc.FindPaymentByID(id)
+		result := paymentValue(payment)
+		s.mu.Unlock()
+		writeResult(w, http.StatusOK, result, err)
+
+	case action == "reject" && r.Method == http.MethodPost:
+		s.mu.Lock()
+		err := s.svc.Reject(id)
+		var payment *types.Payment
+		if err == nil {
+			payment, err = s.svc.FindPaymentByID(id)
+		}
+		result := paymentValue(payment)
+		s.mu.Unlock()
+		writeResult(w, http.StatusOK, result, err)
+
+	case action == "repeat" && r.Method == http.MethodPost:
+		s.mu.Lock()
+		payment, err := s.svc.Repeat(id)
+		result := paymentValue(payment)
+		s.mu.Unlock()
+		writeResult(w, http.StatusCreated, result, err)
+
+	case action == "favorite" && r.Method == http.MethodPost:
+		var req nameRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		s.mu.Lock()
+		favorite, err := s.svc.FavoritePayment(id, req.Name)
+		result := favoriteValue(favorite)
+		s.mu.Unlock()
+		writeResult(w, http.StatusCreated, result, err)
+
+	case action == "" || action == "reject" || action == "repeat" || action == "favorite":
+		writeMethodNotAllowed(w)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+//handleFavorite serves POST /favorites/{id}/pay
+func (s *Server) handleFavorite(w http.ResponseWriter, r *http.Request) {
+
+	parts := splitPath(r.URL.Path, "/favorites/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "pay" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	s.mu.Lock()
+	payment, err := s.svc.PayFromFavorite(parts[0])
+	result := paymentValue(payment)
+	s.mu.Unlock()
+	writeResult(w, http.StatusCreated, result, err)
+}
+
+//accountValue copies account while lock is held, so it is encoded after unlock without race
+func accountValue(account *types.Account) interface{} {
+
+	if account == nil {
+		return nil
+	}
+	return *account
+}
+
+func paymentValue(payment *types.Payment) interface{} {
+
+	if payment == nil {
+		return nil
+	}
+	return *payment
+}
+
+func favoriteValue(favorite *types.Favorite) interface{} {
+
+	if favorite == nil {
+		return nil
+	}
+	return *favorite
+}
+
+func splitPath(path string, prefix string) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(path, prefix), "/"), "/")
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func writeResult(w http.ResponseWriter, status int, v interface{}, err error) {
+
+	if err != nil {
+		writeError(w, wallet.HTTPStatus(err), err)
+		return
+	}
+	writeJSON(w, status, v)
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter) {
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error(), Code: wallet.ErrorCode(err), Details: wallet.ErrorDetails(err)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+func call(t *testing.T, server *httptest.Server, method string, path string, body interface{}, wantStatus int, result interface{}) {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+	req, _ := http.NewRequest(method, server.URL+path, &reader)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s returned error => %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		var e errorResponse
+		json.NewDecoder(resp.Body).Decode(&e)
+		t.Fatalf("%s %s wrong status, want => %v got => %v, error => %v", method, path, wantStatus, resp.StatusCode, e.Error)
+	}
+	if responses, ok := specResponses(method, path); ok && wantStatus != http.StatusMethodNotAllowed && responses[strconv.Itoa(wantStatus)] == nil {
+		t.Errorf("%s %s status %v is not described in OpenAPI", method, path, wantStatus)
+	}
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+		if err != nil {
+			t.Fatalf("%s %s returned bad json => %v", method, path, err)
+		}
+	}
+}
+
+//specResponses returns responses of operation described in OpenAPI for request
+func specResponses(method string, path string) (map[string]interface{}, bool) {
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	json.Unmarshal([]byte(OpenAPI), &spec)
+	path = strings.SplitN(path, "?", 2)[0]
+	for template, operations := range spec.Paths {
+		pattern := "^" + regexp.MustCompile(`\{[^}]+\}`).ReplaceAllString(template, "[^/]+") + "$"
+		if !regexp.MustCompile(pattern).MatchString(path) {
+			continue
+		}
+		var operation struct {
+			Responses map[string]interface{} `json:"responses"`
+		}
+		raw, ok := operations[strings.ToLower(method)]
+		if !ok {
+			return nil, false
+		}
+		json.Unmarshal(raw, &operation)
+		return operation.Responses, true
+	}
+	return nil, false
+}
+
+func TestServer_endToEnd_user(t *testing.T) {
+	server := httptest.NewServer(New(&wallet.Service{}))
+	defer server.Close()
+
+	var account types.Account
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "92 839 38 13"}, http.StatusCreated, &account)
+	if account.ID != 1 || account.Phone != "+992928393813" {
+		t.Errorf("wrong account => %v", account)
+	}
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "+992928393813"}, http.StatusConflict, nil)
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "123"}, http.StatusBadRequest, nil)
+
+	call(t, server, "POST", "/accounts/1/deposit", map[string]int{"amount": 100_00}, http.StatusOK, &account)
+	if account.Balance != 100_00 {
+		t.Errorf("wrong balance => %v", account.Balance)
+	}
+
+	var payment types.Payment
+	call(t, server, "POST", "/accounts/1/payments", map[string]interface{}{"amount": 10_00, "category": "Cafe"}, http.StatusCreated, &payment)
+	call(t, server, "POST", "/accounts/1/payments", map[string]interface{}{"amount": 1000_00, "category": "Cafe"}, http.StatusUnprocessableEntity, nil)
+	call(t, server, "POST", "/accounts/2/payments", map[string]interface{}{"amount": 1, "category": "Cafe"}, http.StatusNotFound, nil)
+
+	var repeated types.Payment
+	call(t, server, "POST", "/payments/"+payment.ID+"/repeat", nil, http.StatusCreated, &repeated)
+
+	var favorite types.Favorite
+	call(t, server, "POST", "/payments/"+payment.ID+"/favorite", map[string]string{"name": "Lunch"}, http.StatusCreated, &favorite)
+	call(t, server, "POST", "/favorites/"+favorite.ID+"/pay", nil, http.StatusCreated, nil)
+
+	var favorites []types.Favorite
+	call(t, server, "GET", "/accounts/1/favorites", nil, http.StatusOK, &favorites)
+	if len(favorites) != 1 || favorites[0].Name != "Lunch" {
+		t.Errorf("wrong favorites => %v", favorites)
+	}
+
+	call(t, server, "POST", "/payments/"+payment.ID+"/reject", nil, http.StatusOK, &payment)
+	if payment.Status != types.PaymentStatusFail {
+		t.Errorf("payment not rejected => %v", payment)
+	}
+	call(t, server, "POST", "/payments/"+payment.ID+"/reject", nil, http.StatusConflict, nil)
+	call(t, server, "GET", "/payments/unknown", nil, http.StatusNotFound, nil)
+
+	var history []types.Payment
+	call(t, server, "GET", "/accounts/1/payments", nil, http.StatusOK, &history)
+	if len(history) != 3 {
+		t.Errorf("wrong history => %v", history)
+	}
+
+	call(t, server, "GET", "/accounts/1", nil, http.StatusOK, &account)
+	if account.Balance != 80_00 {
+		t.Errorf("wrong balance => %v", account.Balance)
+	}
+	call(t, server, "DELETE", "/accounts/1", nil, http.StatusMethodNotAllowed, nil)
+}
+
+func TestServer_Serve_shutdown_user(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- New(&wallet.Service{}).Serve(ctx, listener)
+	}()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/accounts/1")
+	if err != nil {
+		t.Fatalf("server not serving => %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("method Serve returned not nil error, error => %v", err)
+	}
+}
+
+func TestServer_OpenAPI_user(t *testing.T) {
+	server := httptest.NewServer(New(&wallet.Service{}))
+	defer server.Close()
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	call(t, server, "GET", "/openapi.json", nil, http.StatusOK, &spec)
+	if spec.Info.Version != APIVersion {
+		t.Errorf("wrong version => %v", spec.Info.Version)
+	}
+	for _, path := range []string{"/accounts", "/accounts/{accountID}/payments", "/payments/{paymentID}/reject", "/favorites/{favoriteID}/pay"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("path %v not described", path)
+		}
+	}
+	for _, code := range wallet.ErrorCodes() {
+		if !strings.Contains(OpenAPI, `"`+code+`"`) {
+			t.Errorf("error code %v not described", code)
+		}
+	}
+}
+
+//countingSink counts relayed events, it is called only by relay goroutine
+type countingSink struct {
+	count int
+}
+
+func (c *countingSink) Deliver(event types.Event) error {
+	c.count++
+	return nil
+}
+
+func TestServer_withRelay_user(t *testing.T) {
+	svc := &wallet.Service{}
+	server := httptest.NewServer(New(svc))
+	defer server.Close()
+
+	sink := &countingSink{}
+	stop := svc.StartRelay(sink, time.Millisecond)
+
+	var account types.Account
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "+992000000001"}, http.StatusCreated, &account)
+	for i := 0; i < 50; i++ {
+		call(t, server, "POST", fmt.Sprintf("/accounts/%d/deposit", account.ID), map[string]int{"amount": 1}, http.StatusOK, nil)
+	}
+	stop()
+
+	svc.RelayOutbox(sink)
+	if sink.count != 51 {
+		t.Errorf("relay delivered %d events", sink.count)
+	}
+}
+
+func TestServer_parallelDepositsAndReads_user(t *testing.T) {
+	svc := &wallet.Service{}
+	server := httptest.NewServer(New(svc))
+	defer server.Close()
+
+	var account types.Account
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "+992000000001"}, http.StatusCreated, &account)
+	path := fmt.Sprintf("/accounts/%d", account.ID)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			resp, err := server.Client().Post(server.URL+path+"/deposit", "application/json", strings.NewReader(`{"amount": 1}`))
+			if err != nil {
+				t.Errorf("deposit returned error => %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			resp, err := server.Client().Get(server.URL + path)
+			if err != nil {
+				t.Errorf("get returned error => %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	call(t, server, "GET", path, nil, http.StatusOK, &account)
+	if account.Balance != 100 {
+		t.Errorf("wrong balance => %v", account.Balance)
+	}
+}
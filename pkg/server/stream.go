@@ -0,0 +1,243 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+const (
+	streamBacklog   = 1000
+	streamHeartbeat = 15 * time.Second
+)
+
+//streamGap is type of event sent when events after cursor were dropped from backlog
+const streamGap = "gap"
+
+//streamed are events which change balance or payments of account, data.imported goes to every account
+var streamed = map[types.EventType]bool{
+	types.EventPaymentCreated:       true,
+	types.EventPaymentConfirmed:     true,
+	types.EventPaymentRejected:      true,
+	types.EventDeposited:            true,
+	types.EventAccountStatusChanged: true,
+	types.EventPointsRedeemed:       true,
+	types.EventImported:             true,
+}
+
+//streamEvent is data of server-sent event
+type streamEvent struct {
+	ID      string          `json:"id"`
+	Seq     int64           `json:"seq"`
+	Type    types.EventType `json:"type"`
+	At      time.Time       `json:"at"`
+	Amount  types.Money     `json:"amount,omitempty"`
+	Balance types.Money     `json:"balance"`
+	Status  string          `json:"status,omitempty"`
+	Payment *types.Payment  `json:"payment,omitempty"`
+}
+
+//gapEvent is data of gap event, events with seq from From to To may be lost
+type gapEvent struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+//stream keeps last events of every account for resuming and wakes up waiting connections.
+//Events without account are kept under account 0 and go to every account.
+type stream struct {
+	mu      sync.Mutex
+	events  map[int64][]types.Event
+	dropped map[int64]int64 //seq of last event dropped from backlog of account
+	last    int64
+	notify  chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newStream() *stream {
+	return &stream{
+		events:  make(map[int64][]types.Event),
+		dropped: make(map[int64]int64),
+		notify:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+//publish is sync event handler, so it must not block
+func (st *stream) publish(event types.Event) {
+
+	if !streamed[event.Type] {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	events := append(st.events[event.AccountID], event)
+	if len(events) > streamBacklog {
+		st.dropped[event.AccountID] = events[len(events)-streamBacklog-1].Seq
+		events = append([]types.Event(nil), events[len(events)-streamBacklog:]...)
+	}
+	st.events[event.AccountID] = events
+	st.last = event.Seq
+	close(st.notify)
+	st.notify = make(chan struct{})
+}
+
+//since returns kept events of account after seq in order of seq, seq of last lost event or 0 when none was lost,
+//and channel closed on next event
+func (st *stream) since(accountID int64, seq int64) ([]types.Event, int64, <-chan struct{}) {
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	lost := st.dropped[accountID]
+	if st.dropped[0] > lost {
+		lost = st.dropped[0]
+	}
+	if lost <= seq {
+		lost = 0
+	}
+
+	own, common := after(st.events[accountID], seq), after(st.events[0], seq)
+	var events []types.Event
+	for len(own) > 0 || len(common) > 0 {
+		if len(common) == 0 || len(own) > 0 && own[0].Seq < common[0].Seq {
+			events = append(events, own[0])
+			own = own[1:]
+		} else {
+			events = append(events, common[0])
+			common = common[1:]
+		}
+	}
+	return events, lost, st.notify
+}
+
+//after returns events with seq greater than seq, events are sorted by seq
+func after(events []types.Event, seq int64) []types.Event {
+
+	i := sort.Search(len(events), func(i int) bool { return events[i].Seq > seq })
+	return events[i:]
+}
+
+//latest returns seq of last kept event
+func (st *stream) latest() int64 {
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.last
+}
+
+func (st *stream) close() {
+	st.once.Do(func() { close(st.done) })
+}
+
+//handleEvents serves GET /accounts/{id}/events as text/event-stream.
+//Cursor is taken from Last-Event-ID header or last_event_id query, every account keeps backlog of streamBacklog events.
+//When events after cursor were dropped from backlog gap event is sent before kept ones.
+//Without cursor only new events are sent.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, accountID int64) {
+
+	s.mu.Lock()
+	_, err := s.svc.FindAccountByID(accountID)
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, wallet.HTTPStatus(err), err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "streaming not supported"})
+		return
+	}
+
+	cursor := r.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = r.URL.Query().Get("last_event_id")
+	}
+	seq := s.stream.latest()
+	if cursor != "" {
+		seq, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "last event id is invalid"})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, lost, wait := s.stream.since(accountID, seq)
+		if lost != 0 {
+			err = writeGap(w, seq, lost)
+			if err != nil {
+				return
+			}
+			seq = lost
+		}
+		for _, v := range events {
+			err = writeEvent(w, v)
+			if err != nil {
+				return
+			}
+			seq = v.Seq
+		}
+		flusher.Flush()
+
+		select {
+		case <-wait:
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+			if err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.stream.done:
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event types.Event) error {
+
+	data := streamEvent{
+		ID:      event.ID,
+		Seq:     event.Seq,
+		Type:    event.Type,
+		At:      event.At,
+		Amount:  event.Amount,
+		Payment: event.Payment,
+	}
+	if event.Account != nil {
+		data.Balance = event.Account.Balance
+		data.Status = string(event.Account.Status)
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, body)
+	return err
+}
+
+func writeGap(w http.ResponseWriter, seq int64, lost int64) error {
+
+	body, err := json.Marshal(gapEvent{From: seq + 1, To: lost})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", streamGap, body)
+	return err
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  streamEvent
+}
+
+func openStream(t *testing.T, url string, lastEventID string) (<-chan sseEvent, func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req = req.WithContext(ctx)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream returned error => %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("wrong stream response => %v %v", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data)
+			case line == "" && (event.id != "" || event.event != ""):
+				events <- event
+				event = sseEvent{}
+			}
+		}
+	}()
+	return events, cancel
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	return sseEvent{}
+}
+
+func TestServer_events_user(t *testing.T) {
+	server := httptest.NewServer(New(&wallet.Service{}))
+	defer server.Close()
+
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "928393813"}, http.StatusCreated, nil)
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "928393814"}, http.StatusCreated, nil)
+	call(t, server, "GET", "/accounts/3/events", nil, http.StatusNotFound, nil)
+
+	events, cancel := openStream(t, server.URL+"/accounts/1/events", "")
+	call(t, server, "POST", "/accounts/2/deposit", map[string]int{"amount": 500}, http.StatusOK, nil)
+	call(t, server, "POST", "/accounts/1/deposit", map[string]int{"amount": 100_00}, http.StatusOK, nil)
+	var payment types.Payment
+	call(t, server, "POST", "/accounts/1/payments", map[string]interface{}{"amount": 10_00, "category": "Cafe"}, http.StatusCreated, &payment)
+	call(t, server, "POST", "/payments/"+payment.ID+"/reject", nil, http.StatusOK, nil)
+
+	deposited := nextEvent(t, events)
+	if deposited.event != string(types.EventDeposited) || deposited.data.Amount != 100_00 || deposited.data.Balance != 100_00 {
+		t.Errorf("wrong deposit event => %v", deposited)
+	}
+	paid := nextEvent(t, events)
+	if paid.event != string(types.EventPaymentCreated) || paid.data.Balance != 90_00 || paid.data.Payment.ID != payment.ID {
+		t.Errorf("wrong payment event => %v", paid)
+	}
+	rejected := nextEvent(t, events)
+	if rejected.event != string(types.EventPaymentRejected) || rejected.data.Balance != 100_00 {
+		t.Errorf("wrong reject event => %v", rejected)
+	}
+	if strconv.FormatInt(rejected.data.Seq, 10) != rejected.id {
+		t.Errorf("event id is not seq => %v", rejected)
+	}
+	cancel()
+
+	resumed, cancel := openStream(t, server.URL+"/accounts/1/events", deposited.id)
+	defer cancel()
+	if event := nextEvent(t, resumed); event.id != paid.id {
+		t.Errorf("wrong resumed event => %v", event)
+	}
+	if event := nextEvent(t, resumed); event.id != rejected.id {
+		t.Errorf("wrong resumed event => %v", event)
+	}
+
+	var repeated types.Payment
+	call(t, server, "POST", "/payments/"+payment.ID+"/repeat", nil, http.StatusCreated, &repeated)
+	if event := nextEvent(t, resumed); event.data.Payment == nil || event.data.Payment.ID != repeated.ID {
+		t.Errorf("wrong live event after resume => %v", event)
+	}
+
+	call(t, server, "GET", "/accounts/1/events?last_event_id=x", nil, http.StatusBadRequest, nil)
+}
+
+func TestServer_Serve_closesStreams_user(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &wallet.Service{}
+	svc.RegisterAccount("928393813")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- New(svc).Serve(ctx, listener)
+	}()
+
+	events, stop := openStream(t, "http://"+listener.Addr().String()+"/accounts/1/events", "")
+	defer stop()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("method Serve returned not nil error, error => %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream blocked shutdown")
+	}
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("unexpected event")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("stream not closed")
+	}
+}
+
+func TestServer_events_withoutCursorOnlyNew_user(t *testing.T) {
+	server := httptest.NewServer(New(&wallet.Service{}))
+	defer server.Close()
+
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "928393813"}, http.StatusCreated, nil)
+	call(t, server, "POST", "/accounts/1/deposit", map[string]int{"amount": 100}, http.StatusOK, nil)
+
+	events, cancel := openStream(t, server.URL+"/accounts/1/events", "")
+	defer cancel()
+	call(t, server, "POST", "/accounts/1/deposit", map[string]int{"amount": 200}, http.StatusOK, nil)
+
+	if event := nextEvent(t, events); event.data.Amount != 200 {
+		t.Errorf("old event sent without cursor => %v", event)
+	}
+}
+
+func TestStream_backlogPerAccount_user(t *testing.T) {
+	st := newStream()
+
+	st.publish(types.Event{Seq: 1, Type: types.EventDeposited, AccountID: 1})
+	for i := int64(2); i <= streamBacklog+2; i++ {
+		st.publish(types.Event{Seq: i, Type: types.EventDeposited, AccountID: 2})
+	}
+	st.publish(types.Event{Seq: streamBacklog + 3, Type: types.EventImported})
+	st.publish(types.Event{Seq: streamBacklog + 4, Type: types.EventPointsRedeemed, AccountID: 1})
+
+	events, lost, _ := st.since(1, 0)
+	if lost != 0 || len(events) != 3 || events[0].Seq != 1 || events[1].Type != types.EventImported || events[2].Seq != streamBacklog+4 {
+		t.Errorf("wrong events of account 1 => %v, lost => %v", events, lost)
+	}
+
+	events, lost, _ = st.since(2, 0)
+	if lost != 2 || len(events) != streamBacklog+1 || events[0].Seq != 3 {
+		t.Errorf("wrong events of account 2 => %d, lost => %v", len(events), lost)
+	}
+	if _, lost, _ = st.since(2, 2); lost != 0 {
+		t.Errorf("gap reported after cursor past lost events => %v", lost)
+	}
+}
+
+func TestServer_events_gap_user(t *testing.T) {
+	svc := &wallet.Service{}
+	server := httptest.NewServer(New(svc))
+	defer server.Close()
+
+	call(t, server, "POST", "/accounts", map[string]string{"phone": "928393813"}, http.StatusCreated, nil)
+	for i := 0; i < streamBacklog+1; i++ {
+		svc.Deposit(1, 1)
+	}
+
+	events, cancel := openStream(t, server.URL+"/accounts/1/events", "1")
+	defer cancel()
+	if event := nextEvent(t, events); event.event != streamGap || event.id != "" {
+		t.Errorf("gap was not reported => %v", event)
+	}
+	if event := nextEvent(t, events); event.id != "3" {
+		t.Errorf("wrong first kept event => %v", event)
+	}
+}
@@ -0,0 +1,428 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+//ErrUnknownCommand -- command is not supported by shell
+var ErrUnknownCommand = errors.New("unknown command")
+
+//ErrWrongArguments -- wrong number or format of command arguments
+var ErrWrongArguments = errors.New("wrong arguments")
+
+//LastPayment is replaced with ID of last created payment, e.g. reject $
+const LastPayment = "$"
+
+const prompt = "wallet> "
+
+type argKind int
+
+const (
+	argText argKind = iota
+	argAccount
+	argPayment
+	argFavorite
+)
+
+type command struct {
+	args     []argKind
+	optional int
+	usage    string
+	run      func(sh *Shell, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"register": {[]argKind{argText}, 0, "register <phone>", (*Shell).register},
+		"deposit":  {[]argKind{argAccount, argText}, 0, "deposit <accountID> <amount>", (*Shell).deposit},
+		"pay":      {[]argKind{argAccount, argText, argText}, 0, "pay <accountID> <amount> <category>", (*Shell).pay},
+		"reject":   {[]argKind{argPayment}, 0, "reject <paymentID>", (*Shell).reject},
+		"repeat":   {[]argKind{argPayment}, 0, "repeat <paymentID>", (*Shell).repeat},
+		"favorite": {[]argKind{argPayment, argText}, 0, "favorite <paymentID> <name>", (*Shell).favorite},
+		"payfav":   {[]argKind{argFavorite}, 0, "payfav <favoriteID>", (*Shell).payFavorite},
+		"balance":  {[]argKind{argAccount}, 0, "balance <accountID>", (*Shell).balance},
+		"accounts": {nil, 0, "accounts", (*Shell).accounts},
+		"payments": {[]argKind{argAccount}, 0, "payments <accountID>", (*Shell).payments},
+		"sum":      {nil, 0, "sum", (*Shell).sum},
+		"save":     {[]argKind{argText}, 1, "save [dir]", (*Shell).save},
+		"load":     {[]argKind{argText}, 1, "load [dir]", (*Shell).load},
+		"history":  {nil, 0, "history", (*Shell).printHistory},
+		"help":     {nil, 0, "help", (*Shell).help},
+		"exit":     {nil, 0, "exit", (*Shell).exit},
+	}
+}
+
+//Shell runs wallet commands typed by user
+type Shell struct {
+	svc         *wallet.Service
+	dir         string
+	out         io.Writer
+	history     []string
+	lastPayment string
+	done        bool
+}
+
+//New creates shell, dir is used by save and load without argument
+func New(svc *wallet.Service, dir string, out io.Writer) *Shell {
+	return &Shell{svc: svc, dir: dir, out: out}
+}
+
+//History returns executed lines, oldest first
+func (sh *Shell) History() []string {
+	return append([]string(nil), sh.history...)
+}
+
+//Run reads lines until exit or end of input, errors of commands are printed and do not stop shell.
+//When terminal is true in must be in non canonical mode without echo, then tab completes and arrows walk history.
+func (sh *Shell) Run(in io.Reader, terminal bool) error {
+
+	reader := newLineReader(in, sh.out, terminal, sh.Complete)
+	for !sh.done {
+		line, err := reader.readLine(prompt, sh.history)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = sh.Exec(line)
+		if err != nil {
+			fmt.Fprintln(sh.out, "error:", err)
+		}
+	}
+	return nil
+}
+
+//Exec executes one line and adds it to history
+func (sh *Shell) Exec(line string) error {
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	sh.history = append(sh.history, strings.Join(fields, " "))
+
+	cmd, ok := commands[fields[0]]
+	if !ok {
+		return fmt.Errorf("%w %q, try help", ErrUnknownCommand, fields[0])
+	}
+	args := fields[1:]
+	if len(args) > len(cmd.args) || len(args) < len(cmd.args)-cmd.optional {
+		return fmt.Errorf("%w, usage: %s", ErrWrongArguments, cmd.usage)
+	}
+	for i, v := range args {
+		if v == LastPayment && cmd.args[i] == argPayment {
+			if sh.lastPayment == "" {
+				return fmt.Errorf("%w, no payment yet for %s", ErrWrongArguments, LastPayment)
+			}
+			args[i] = sh.lastPayment
+		}
+	}
+	return cmd.run(sh, args)
+}
+
+//Complete returns candidates for last word of line: commands, account, payment or favorite IDs
+func (sh *Shell) Complete(line string) []string {
+
+	fields := strings.Fields(line)
+	word := ""
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	var candidates []string
+	if len(fields) == 0 {
+		for name := range commands {
+			candidates = append(candidates, name)
+		}
+	} else {
+		cmd, ok := commands[fields[0]]
+		position := len(fields) - 1
+		if !ok || position >= len(cmd.args) {
+			return nil
+		}
+		candidates = sh.values(cmd.args[position])
+	}
+
+	var matched []string
+	for _, v := range candidates {
+		if strings.HasPrefix(v, word) {
+			matched = append(matched, v)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+func (sh *Shell) values(kind argKind) []string {
+
+	var values []string
+	switch kind {
+	case argAccount:
+		for _, v := range sh.svc.Accounts() {
+			values = append(values, strconv.FormatInt(v.ID, 10))
+		}
+	case argPayment:
+		payments, _ := sh.svc.FilterPaymentsByFn(func(payment types.Payment) bool { return true }, 1)
+		for _, v := range payments {
+			values = append(values, v.ID)
+		}
+	case argFavorite:
+		for _, account := range sh.svc.Accounts() {
+			favorites, _ := sh.svc.AccountFavorites(account.ID)
+			for _, v := range favorites {
+				values = append(values, v.ID)
+			}
+		}
+	}
+	return values
+}
+
+//FormatMoney prints amount in dirams as somoni with two decimals
+func FormatMoney(amount types.Money) string {
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+func (sh *Shell) register(args []string) error {
+
+	account, err := sh.svc.RegisterAccount(types.Phone(args[0]))
+	if err != nil {
+		return err
+	}
+	return sh.printAccounts([]types.Account{*account})
+}
+
+func (sh *Shell) deposit(args []string) error {
+
+	accountID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(args[1])
+	if err != nil {
+		return err
+	}
+	err = sh.svc.Deposit(accountID, amount)
+	if err != nil {
+		return err
+	}
+	return sh.balance(args[:1])
+}
+
+func (sh *Shell) pay(args []string) error {
+
+	accountID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(args[1])
+	if err != nil {
+		return err
+	}
+	payment, err := sh.svc.Pay(accountID, amount, types.PaymentCategory(args[2]))
+	if err != nil {
+		return err
+	}
+	sh.lastPayment = payment.ID
+	return sh.printPayments([]types.Payment{*payment})
+}
+
+func (sh *Shell) reject(args []string) error {
+
+	err := sh.svc.Reject(args[0])
+	if err != nil {
+		return err
+	}
+	payment, err := sh.svc.FindPaymentByID(args[0])
+	if err != nil {
+		return err
+	}
+	return sh.printPayments([]types.Payment{*payment})
+}
+
+func (sh *Shell) repeat(args []string) error {
+
+	payment, err := sh.svc.Repeat(args[0])
+	if err != nil {
+		return err
+	}
+	sh.lastPayment = payment.ID
+	return sh.printPayments([]types.Payment{*payment})
+}
+
+func (sh *Shell) favorite(args []string) error {
+
+	favorite, err := sh.svc.FavoritePayment(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(sh.out, "favorite %s %q %s %s\n", favorite.ID, favorite.Name, FormatMoney(favorite.Amount), favorite.Category)
+	return err
+}
+
+func (sh *Shell) payFavorite(args []string) error {
+
+	payment, err := sh.svc.PayFromFavorite(args[0])
+	if err != nil {
+		return err
+	}
+	sh.lastPayment = payment.ID
+	return sh.printPayments([]types.Payment{*payment})
+}
+
+func (sh *Shell) balance(args []string) error {
+
+	accountID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	account, err := sh.svc.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(sh.out, "account %d balance %s\n", account.ID, FormatMoney(account.Balance))
+	return err
+}
+
+func (sh *Shell) accounts(args []string) error {
+	return sh.printAccounts(sh.svc.Accounts())
+}
+
+func (sh *Shell) payments(args []string) error {
+
+	accountID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	payments, err := sh.svc.ExportAccountHistory(accountID)
+	if err != nil {
+		return err
+	}
+	return sh.printPayments(payments)
+}
+
+func (sh *Shell) sum(args []string) error {
+
+	_, err := fmt.Fprintln(sh.out, FormatMoney(sh.svc.SumPayments(1)))
+	return err
+}
+
+func (sh *Shell) save(args []string) error {
+
+	dir := sh.dir
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	err = sh.svc.Export(dir)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(sh.out, "saved to", dir)
+	return err
+}
+
+func (sh *Shell) load(args []string) error {
+
+	dir := sh.dir
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	err := sh.svc.Import(dir)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(sh.out, "loaded from", dir)
+	return err
+}
+
+func (sh *Shell) printHistory(args []string) error {
+
+	for i, v := range sh.history {
+		_, err := fmt.Fprintf(sh.out, "%4d  %s\n", i+1, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sh *Shell) help(args []string) error {
+
+	var usages []string
+	for _, v := range commands {
+		usages = append(usages, v.usage)
+	}
+	sort.Strings(usages)
+	for _, v := range usages {
+		fmt.Fprintln(sh.out, " ", v)
+	}
+	_, err := fmt.Fprintln(sh.out, "  use", LastPayment, "as paymentID of last created payment")
+	return err
+}
+
+func (sh *Shell) exit(args []string) error {
+
+	sh.done = true
+	return nil
+}
+
+func (sh *Shell) printAccounts(accounts []types.Account) error {
+
+	w := tabwriter.NewWriter(sh.out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "ID\tPHONE\tBALANCE\tSTATUS\t")
+	for _, v := range accounts {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", v.ID, v.Phone, FormatMoney(v.Balance), v.Status)
+	}
+	return w.Flush()
+}
+
+func (sh *Shell) printPayments(payments []types.Payment) error {
+
+	w := tabwriter.NewWriter(sh.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tACCOUNT\tAMOUNT\tCATEGORY\tSTATUS")
+	for _, v := range payments {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", v.ID, v.AccountID, FormatMoney(v.Amount), v.Category, v.Status)
+	}
+	return w.Flush()
+}
+
+func parseID(value string) (int64, error) {
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w, account id %q is not a number", ErrWrongArguments, value)
+	}
+	return id, nil
+}
+
+func parseAmount(value string) (types.Money, error) {
+
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w, amount %q is not a number", ErrWrongArguments, value)
+	}
+	return types.Money(amount), nil
+}
@@ -0,0 +1,140 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+	"github.com/khushbakhtmahkamov/wallet/pkg/wallet"
+)
+
+func TestShell_Exec_payRejectRepeat_user(t *testing.T) {
+	svc := &wallet.Service{}
+	var out bytes.Buffer
+	sh := New(svc, "", &out)
+
+	for _, line := range []string{
+		"register 928393813",
+		"deposit 1 10000",
+		"pay 1 1050 Cafe",
+		"reject $",
+	} {
+		err := sh.Exec(line)
+		if err != nil {
+			t.Fatalf("%v returned not nil error, error => %v", line, err)
+		}
+	}
+	rejected := sh.lastPayment
+	err := sh.Exec("repeat $")
+	if err != nil {
+		t.Fatalf("repeat returned not nil error, error => %v", err)
+	}
+
+	payment, _ := svc.FindPaymentByID(rejected)
+	if payment.Status != types.PaymentStatusFail {
+		t.Errorf("payment not rejected => %v", payment)
+	}
+	account, _ := svc.FindAccountByID(1)
+	if account.Balance != 100_00-10_50 {
+		t.Errorf("wrong balance => %v", account.Balance)
+	}
+
+	out.Reset()
+	sh.Exec("balance 1")
+	if out.String() != "account 1 balance 89.50\n" {
+		t.Errorf("wrong balance output => %q", out.String())
+	}
+}
+
+func TestShell_Exec_errors_user(t *testing.T) {
+	sh := New(&wallet.Service{}, "", ioutil.Discard)
+
+	err := sh.Exec("unknown")
+	if !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("wrong error => %v", err)
+	}
+	err = sh.Exec("deposit 1")
+	if !errors.Is(err, ErrWrongArguments) {
+		t.Errorf("wrong error => %v", err)
+	}
+	err = sh.Exec("reject $")
+	if !errors.Is(err, ErrWrongArguments) {
+		t.Errorf("wrong error => %v", err)
+	}
+	err = sh.Exec("balance 1")
+	if !errors.Is(err, wallet.ErrAccountNotFound) {
+		t.Errorf("wrong error => %v", err)
+	}
+
+	want := []string{"unknown", "deposit 1", "reject $", "balance 1"}
+	if !reflect.DeepEqual(sh.History(), want) {
+		t.Errorf("wrong history => %v", sh.History())
+	}
+}
+
+func TestShell_Complete_user(t *testing.T) {
+	svc := &wallet.Service{}
+	sh := New(svc, "", ioutil.Discard)
+	sh.Exec("register 928393813")
+	sh.Exec("register 928393814")
+	sh.Exec("deposit 1 100")
+	sh.Exec("pay 1 10 Cafe")
+
+	if got := sh.Complete("re"); !reflect.DeepEqual(got, []string{"register", "reject", "repeat"}) {
+		t.Errorf("wrong commands => %v", got)
+	}
+	if got := sh.Complete("balance "); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("wrong accounts => %v", got)
+	}
+	if got := sh.Complete("reject " + sh.lastPayment[:4]); !reflect.DeepEqual(got, []string{sh.lastPayment}) {
+		t.Errorf("wrong payments => %v", got)
+	}
+	if got := sh.Complete("balance 1 "); got != nil {
+		t.Errorf("wrong completion after last argument => %v", got)
+	}
+}
+
+func TestShell_Run_saveLoad_user(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var out bytes.Buffer
+	script := "register 928393813\ndeposit 1 500\nbad\nsave\nexit\nregister 928393814\n"
+	err = New(&wallet.Service{}, dir, &out).Run(strings.NewReader(script), false)
+	if err != nil {
+		t.Fatalf("method Run returned not nil error, error => %v", err)
+	}
+	if !strings.Contains(out.String(), "error: unknown command") {
+		t.Errorf("error not printed => %v", out.String())
+	}
+
+	svc := &wallet.Service{}
+	out.Reset()
+	err = New(svc, dir, &out).Run(strings.NewReader("load\naccounts"), false)
+	if err != nil {
+		t.Fatalf("method Run returned not nil error, error => %v", err)
+	}
+	accounts := svc.Accounts()
+	if len(accounts) != 1 || accounts[0].Balance != 500 {
+		t.Errorf("wrong accounts after load => %v", accounts)
+	}
+	if !strings.Contains(out.String(), "5.00") {
+		t.Errorf("balance not pretty printed => %v", out.String())
+	}
+}
+
+func TestFormatMoney_user(t *testing.T) {
+	for amount, want := range map[types.Money]string{0: "0.00", 5: "0.05", 10_50: "10.50", -1_01: "-1.01"} {
+		if got := FormatMoney(amount); got != want {
+			t.Errorf("wrong format of %v, want => %v got => %v", int64(amount), want, got)
+		}
+	}
+}
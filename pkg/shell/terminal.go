@@ -0,0 +1,142 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyTab       = '\t'
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+type lineReader struct {
+	in       *bufio.Reader
+	out      io.Writer
+	terminal bool
+	complete func(line string) []string
+}
+
+func newLineReader(in io.Reader, out io.Writer, terminal bool, complete func(line string) []string) *lineReader {
+	return &lineReader{in: bufio.NewReader(in), out: out, terminal: terminal, complete: complete}
+}
+
+//readLine reads one line, io.EOF is returned only when nothing was typed
+func (r *lineReader) readLine(prompt string, history []string) (string, error) {
+
+	if !r.terminal {
+		line, err := r.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	fmt.Fprint(r.out, prompt)
+	line := []rune{}
+	position := len(history)
+	for {
+		ch, _, err := r.in.ReadRune()
+		if err == io.EOF && len(line) > 0 {
+			fmt.Fprintln(r.out)
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch ch {
+		case '\r', '\n':
+			fmt.Fprintln(r.out)
+			return string(line), nil
+
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprintln(r.out)
+				return "", io.EOF
+			}
+
+		case keyCtrlC:
+			line = line[:0]
+			fmt.Fprint(r.out, "^C\n", prompt)
+
+		case keyBackspace, keyDelete:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Fprint(r.out, "\b \b")
+			}
+
+		case keyTab:
+			line = r.completeLine(prompt, line)
+
+		case keyEscape:
+			// arrows come as ESC [ A and ESC [ B
+			next, _, err := r.in.ReadRune()
+			if err != nil || next != '[' {
+				continue
+			}
+			arrow, _, err := r.in.ReadRune()
+			if err != nil {
+				continue
+			}
+			switch {
+			case arrow == 'A' && position > 0:
+				position--
+				line = []rune(history[position])
+			case arrow == 'B' && position < len(history)-1:
+				position++
+				line = []rune(history[position])
+			case arrow == 'B':
+				position = len(history)
+				line = line[:0]
+			default:
+				continue
+			}
+			r.redraw(prompt, line)
+
+		default:
+			if ch >= ' ' {
+				line = append(line, ch)
+				fmt.Fprint(r.out, string(ch))
+			}
+		}
+	}
+}
+
+//completeLine extends last word by common prefix of candidates, candidates are listed when ambiguous
+func (r *lineReader) completeLine(prompt string, line []rune) []rune {
+
+	text := string(line)
+	candidates := r.complete(text)
+	if len(candidates) == 0 {
+		return line
+	}
+
+	word := text[strings.LastIndex(text, " ")+1:]
+	common := candidates[0]
+	for _, v := range candidates[1:] {
+		for !strings.HasPrefix(v, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	if len(candidates) == 1 {
+		common += " "
+	} else if common == word {
+		fmt.Fprintln(r.out)
+		fmt.Fprintln(r.out, strings.Join(candidates, "  "))
+	}
+	line = []rune(text[:len(text)-len(word)] + common)
+	r.redraw(prompt, line)
+	return line
+}
+
+func (r *lineReader) redraw(prompt string, line []rune) {
+	fmt.Fprint(r.out, "\r\x1b[K", prompt, string(line))
+}
@@ -0,0 +1,32 @@
+package shell
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLineReader_completeAndHistory_user(t *testing.T) {
+	complete := func(line string) []string {
+		if strings.HasPrefix("register", line) {
+			return []string{"register"}
+		}
+		return nil
+	}
+	input := "reg\t928\x7f\x7f\x7f123\n" + "\x1b[A\x1b[A\n" + "x\x03\x04\x04"
+	r := newLineReader(strings.NewReader(input), ioutil.Discard, true, complete)
+
+	line, err := r.readLine("> ", nil)
+	if err != nil || line != "register 123" {
+		t.Fatalf("wrong line => %q, error => %v", line, err)
+	}
+	line, err = r.readLine("> ", []string{"first", "second"})
+	if err != nil || line != "first" {
+		t.Fatalf("wrong history line => %q, error => %v", line, err)
+	}
+	_, err = r.readLine("> ", nil)
+	if err != io.EOF {
+		t.Fatalf("wrong error => %v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type Money int64
 
 type PaymentCategory string
@@ -44,19 +46,28 @@ type Card struct {
 }
 
 type Payment struct {
-	ID        string
-	AccountID int64
-	Amount    Money
-	Category  PaymentCategory
-	Status    PaymentStatus
+	ID        string          `json:"id"`
+	AccountID int64           `json:"account_id"`
+	Amount    Money           `json:"amount"`
+	Category  PaymentCategory `json:"category"`
+	Status    PaymentStatus   `json:"status"`
 }
 
 type Phone string
 
+type AccountStatus string
+
+const (
+	AccountActive AccountStatus = "ACTIVE"
+	AccountFrozen AccountStatus = "FROZEN"
+	AccountClosed AccountStatus = "CLOSED"
+)
+
 type Account struct {
-	ID      int64
-	Phone   Phone
-	Balance Money
+	ID      int64         `json:"id"`
+	Phone   Phone         `json:"phone"`
+	Balance Money         `json:"balance"`
+	Status  AccountStatus `json:"status"`
 }
 
 type PaymentSource struct {
@@ -66,14 +77,225 @@ type PaymentSource struct {
 }
 
 type Favorite struct {
+	ID        string          `json:"id"`
+	AccountID int64           `json:"account_id"`
+	Name      string          `json:"name"`
+	Amount    Money           `json:"amount"`
+	Category  PaymentCategory `json:"category"`
+}
+
+type Progress struct {
+	Part   int
+	Result Money
+}
+
+//OperationProgress of long operation, Result is sum and Matched is count of payments kept so far.
+//Last event of operation has Done set and Err when operation failed.
+type OperationProgress struct {
+	Op        string
+	Processed int
+	Total     int
+	Result    Money
+	Matched   int
+	Elapsed   time.Duration
+	ETA       time.Duration
+	Done      bool
+	Err       error
+}
+
+type RewardKind string
+
+const (
+	RewardCashback RewardKind = "CASHBACK"
+	RewardPoints   RewardKind = "POINTS"
+)
+
+type RewardRule struct {
+	ID         string
+	Category   PaymentCategory
+	Kind       RewardKind
+	Percent    int64
+	MonthlyCap Money
+}
+
+type Reward struct {
+	ID        string
+	RuleID    string
+	PaymentID string
+	AccountID int64
+	Kind      RewardKind
+	Amount    Money
+	Period    string
+	Reversed  bool
+}
+
+type ScheduleKind string
+
+const (
+	ScheduleOnce    ScheduleKind = "ONCE"
+	ScheduleDaily   ScheduleKind = "DAILY"
+	ScheduleWeekly  ScheduleKind = "WEEKLY"
+	ScheduleMonthly ScheduleKind = "MONTHLY"
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleActive ScheduleStatus = "ACTIVE"
+	SchedulePaused ScheduleStatus = "PAUSED"
+	ScheduleDone   ScheduleStatus = "DONE"
+)
+
+type Schedule struct {
+	ID            string
+	FavoriteID    string
+	Kind          ScheduleKind
+	Day           int
+	RunAt         time.Time
+	NextRun       time.Time
+	MaxRetries    int
+	RetryInterval time.Duration
+	Retries       int
+	Status        ScheduleStatus
+}
+
+type ScheduleExecution struct {
+	ScheduleID string
+	PaymentID  string
+	At         time.Time
+	Attempt    int
+	Error      string
+}
+
+type BatchMode string
+
+const (
+	BatchAllOrNothing BatchMode = "ALL_OR_NOTHING"
+	BatchBestEffort   BatchMode = "BEST_EFFORT"
+)
+
+type BatchItem struct {
+	AccountID int64
+	Amount    Money
+	Category  PaymentCategory
+}
+
+type MoneyRequestStatus string
+
+const (
+	MoneyRequestPending  MoneyRequestStatus = "PENDING"
+	MoneyRequestAccepted MoneyRequestStatus = "ACCEPTED"
+	MoneyRequestDeclined MoneyRequestStatus = "DECLINED"
+	MoneyRequestExpired  MoneyRequestStatus = "EXPIRED"
+)
+
+type MoneyRequestShare struct {
+	Phone     Phone
+	Amount    Money
+	Status    MoneyRequestStatus
+	PaymentID string
+}
+
+type MoneyRequest struct {
 	ID        string
 	AccountID int64
+	Category  PaymentCategory
+	Shares    []MoneyRequestShare
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type PhoneChange struct {
+	AccountID int64
+	OldPhone  Phone
+	NewPhone  Phone
+	ChangedAt time.Time
+}
+
+type Role string
+
+const (
+	RoleCustomer Role = "CUSTOMER"
+	RoleSupport  Role = "SUPPORT"
+	RoleAdmin    Role = "ADMIN"
+	RoleAuditor  Role = "AUDITOR"
+)
+
+type Actor struct {
 	Name      string
+	Role      Role
+	AccountID int64
+}
+
+type AccessDenial struct {
+	Actor      Actor
+	Permission string
+	AccountID  int64
+	At         time.Time
+}
+
+type AuditRecord struct {
+	Seq      int64
+	Actor    string
+	Action   string
+	At       time.Time
+	Before   string
+	After    string
+	PrevHash string
+	Hash     string
+}
+
+type EventType string
+
+const (
+	EventAccountRegistered    EventType = "account.registered"
+	EventAccountStatusChanged EventType = "account.status_changed"
+	EventPhoneChanged         EventType = "account.phone_changed"
+	EventDeposited            EventType = "account.deposited"
+	EventPointsRedeemed       EventType = "account.points_redeemed"
+	EventPaymentCreated       EventType = "payment.created"
+	EventPaymentConfirmed     EventType = "payment.confirmed"
+	EventPaymentRejected      EventType = "payment.rejected"
+	EventFavoriteCreated      EventType = "favorite.created"
+	EventFavoriteUpdated      EventType = "favorite.updated"
+	EventFavoriteDeleted      EventType = "favorite.deleted"
+	EventImported             EventType = "data.imported"
+)
+
+type Event struct {
+	ID        string
+	Seq       int64
+	Type      EventType
+	AccountID int64
+	At        time.Time
 	Amount    Money
+	Account   *Account
+	Payment   *Payment
+	Favorite  *Favorite
+	From      PaymentStatus
+}
+
+type OutboxEntry struct {
+	Event       Event
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	Delivered   bool
+}
+
+type Webhook struct {
+	ID        string
+	URL       string
+	AccountID int64
 	Category  PaymentCategory
 }
 
-type Progress struct {
-	Part   int
-	Result Money
+type WebhookDelivery struct {
+	ID          string
+	WebhookID   string
+	EventID     string
+	Payload     []byte
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
 }
@@ -0,0 +1,191 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrAuditTampered -- audit log was modified or records were removed
+var ErrAuditTampered = errors.New("audit log tampered")
+
+const systemActor = "system"
+
+type paymentChange struct {
+	Account interface{}
+	Payment interface{}
+}
+
+type stateCounts struct {
+	Accounts  int
+	Payments  int
+	Favorites int
+}
+
+//AuditLog returns copy of audit records
+func (s *Service) AuditLog() []types.AuditRecord {
+
+	records := make([]types.AuditRecord, len(s.auditLog))
+	copy(records, s.auditLog)
+	return records
+}
+
+//AuditHead returns hash of last audit record, keep it outside to detect removed tail
+func (s *Service) AuditHead() string {
+
+	if len(s.auditLog) == 0 {
+		return ""
+	}
+	return s.auditLog[len(s.auditLog)-1].Hash
+}
+
+//VerifyAudit walks records and checks hash chain ends with head
+func VerifyAudit(records []types.AuditRecord, head string) error {
+
+	prev := ""
+	for i, v := range records {
+		if v.Seq != int64(i+1) {
+			return fmt.Errorf("%w: record %d has sequence %d", ErrAuditTampered, i+1, v.Seq)
+		}
+		if v.PrevHash != prev {
+			return fmt.Errorf("%w: record %d is not linked to previous", ErrAuditTampered, v.Seq)
+		}
+		if hashAudit(v) != v.Hash {
+			return fmt.Errorf("%w: record %d was modified", ErrAuditTampered, v.Seq)
+		}
+		prev = v.Hash
+	}
+	if prev != head {
+		return fmt.Errorf("%w: log does not end with head", ErrAuditTampered)
+	}
+	return nil
+}
+
+func (s *Service) actingAs(actor types.Actor) func() {
+
+	previous := s.actor
+	s.actor = actor.Name
+	return func() {
+		s.actor = previous
+	}
+}
+
+func (s *Service) audit(action string, before interface{}, after interface{}) {
+	s.auditRaw(action, marshalAudit(before), marshalAudit(after))
+}
+
+func (s *Service) auditRaw(action string, before string, after string) {
+
+	actor := s.actor
+	if actor == "" {
+		actor = systemActor
+	}
+	record := types.AuditRecord{
+		Seq:      int64(len(s.auditLog) + 1),
+		Actor:    actor,
+		Action:   action,
+		At:       s.currentTime(),
+		Before:   before,
+		After:    after,
+		PrevHash: s.AuditHead(),
+	}
+	record.Hash = hashAudit(record)
+	s.auditLog = append(s.auditLog, record)
+}
+
+func (s *Service) exportAudit(t *tracker) (string, error) {
+
+	var str strings.Builder
+	for _, v := range s.auditLog {
+		if err := t.check(); err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		str.WriteString(string(data) + "\n")
+		t.add(1)
+	}
+	return str.String(), nil
+}
+
+//importAudit restores audit log when dump continues it, so hash chain survives restart.
+//Log of other service is not taken, current log is kept.
+func (s *Service) importAudit(t *tracker, lines []string) error {
+
+	var records []types.AuditRecord
+	for i, line := range lines {
+		if err := t.check(); err != nil {
+			return err
+		}
+		var record types.AuditRecord
+		err := json.Unmarshal([]byte(line), &record)
+		if err != nil {
+			return importError("audit.dump", i+1, err)
+		}
+		records = append(records, record)
+		t.add(1)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	err := VerifyAudit(records, records[len(records)-1].Hash)
+	if err != nil {
+		return err
+	}
+
+	if len(records) <= len(s.auditLog) {
+		return nil
+	}
+	for i, v := range s.auditLog {
+		if records[i].Hash != v.Hash {
+			return nil
+		}
+	}
+	s.auditLog = records
+	return nil
+}
+
+func (s *Service) accountSnapshot(accountID int64) interface{} {
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil
+	}
+	return *account
+}
+
+func (s *Service) stateCounts() stateCounts {
+	return stateCounts{
+		Accounts:  len(s.accounts),
+		Payments:  len(s.payments),
+		Favorites: len(s.favorites),
+	}
+}
+
+func marshalAudit(v interface{}) string {
+
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func hashAudit(record types.AuditRecord) string {
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%q\n%q\n%s\n%q\n%q\n%q", record.Seq, record.Actor, record.Action,
+		record.At.UTC().Format(time.RFC3339Nano), record.Before, record.After, record.PrevHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,153 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestService_AuditLog_user(t *testing.T) {
+	var svc Service
+
+	admin := svc.As(types.Actor{Name: "root", Role: types.RoleAdmin})
+	account, _ := admin.RegisterAccount("+992000000001")
+	admin.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	svc.FavoritePayment(payment.ID, "Lunch")
+	svc.Repeat(payment.ID)
+	svc.Reject(payment.ID)
+
+	records := svc.AuditLog()
+	actions := []string{"account.register", "account.deposit", "payment.pay", "favorite.create", "payment.pay", "payment.repeat", "payment.reject"}
+	if len(records) != len(actions) {
+		t.Fatalf("wrong records count => %v", len(records))
+	}
+	for i, v := range records {
+		if v.Action != actions[i] {
+			t.Errorf("record %d wrong action, want => %v got => %v", i, actions[i], v.Action)
+		}
+	}
+	if records[0].Actor != "root" || records[2].Actor != systemActor {
+		t.Errorf("wrong actors => %v %v", records[0].Actor, records[2].Actor)
+	}
+	if records[1].Before == records[1].After {
+		t.Errorf("deposit before and after are same => %v", records[1].Before)
+	}
+
+	head := svc.AuditHead()
+	if err := VerifyAudit(records, head); err != nil {
+		t.Errorf("method VerifyAudit returned not nil error, error => %v", err)
+	}
+
+	modified := svc.AuditLog()
+	modified[1].After = `{"ID":1,"Balance":1000000}`
+	if err := VerifyAudit(modified, head); !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("modified record not detected, error => %v", err)
+	}
+
+	removed := append(svc.AuditLog()[:2], svc.AuditLog()[3:]...)
+	if err := VerifyAudit(removed, head); !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("removed record not detected, error => %v", err)
+	}
+
+	truncated := svc.AuditLog()[:len(records)-1]
+	if err := VerifyAudit(truncated, head); !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("removed tail not detected, error => %v", err)
+	}
+}
+
+func TestService_AuditLog_survivesExportImport_user(t *testing.T) {
+	var svc Service
+	dir := t.TempDir()
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.Pay(account.ID, 10_00, "Cafe")
+	err := svc.Export(dir)
+	if err != nil {
+		t.Fatalf("method Export returned not nil error, error => %v", err)
+	}
+	head := svc.AuditHead()
+
+	var restarted Service
+	err = restarted.Import(dir)
+	if err != nil {
+		t.Fatalf("method Import returned not nil error, error => %v", err)
+	}
+	records := restarted.AuditLog()
+	if len(records) != 4 || records[2].Hash != head || records[3].Action != "data.import" {
+		t.Fatalf("audit log was not restored => %v", records)
+	}
+	if err := VerifyAudit(records, restarted.AuditHead()); err != nil {
+		t.Errorf("restored chain is broken, error => %v", err)
+	}
+}
+
+func TestService_Import_tamperedAudit_user(t *testing.T) {
+	var svc Service
+	dir := t.TempDir()
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.Export(dir)
+	content, _ := ioutil.ReadFile(dir + "/audit.dump")
+	content = []byte(strings.Replace(string(content), "10000", "90000", 1))
+	ioutil.WriteFile(dir+"/audit.dump", content, 0666)
+
+	var restarted Service
+	err := restarted.Import(dir)
+	if !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("method Import returned wrong error, error => %v", err)
+	}
+	if len(restarted.accounts) != 0 {
+		t.Errorf("tampered dump was imported => %v", restarted.accounts)
+	}
+}
+
+func TestService_Import_failedAudited_user(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(dir+"/accounts.dump", []byte("1;+992000000001;0;ACTIVE\nbad\n"), 0666)
+
+	var svc Service
+	err := svc.Import(dir)
+	if !errors.Is(err, ErrDumpInvalid) {
+		t.Fatalf("method Import returned wrong error, error => %v", err)
+	}
+	records := svc.AuditLog()
+	if len(records) != 1 || records[0].Action != "data.import_failed" {
+		t.Errorf("failed import was not audited => %v", records)
+	}
+}
+
+func TestService_AuditLog_moreActions_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.AddRewardRule("Cafe", types.RewardPoints, 10, 0)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	svc.Confirm(payment.ID)
+	svc.RedeemPoints(account.ID, 1_00)
+	svc.ChangePhone(account.ID, "+992000000002")
+
+	var actions []string
+	for _, v := range svc.AuditLog()[3:] {
+		actions = append(actions, v.Action)
+	}
+	want := "[payment.confirm points.redeem account.phone_change]"
+	if got := fmt.Sprint(actions); got != want {
+		t.Errorf("wrong audit actions => %v", got)
+	}
+}
+
+func TestHashAudit_fieldsNotShifted_user(t *testing.T) {
+	a := types.AuditRecord{Seq: 1, Actor: "root\nx", Action: "y"}
+	b := types.AuditRecord{Seq: 1, Actor: "root", Action: "x\ny"}
+	if hashAudit(a) == hashAudit(b) {
+		t.Errorf("records with shifted fields have same hash")
+	}
+}
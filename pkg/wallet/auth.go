@@ -0,0 +1,378 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrPINInvalid -- PIN must be 4 to 6 digits
+var ErrPINInvalid = errors.New("pin is invalid")
+
+//ErrPINAlreadySet -- PIN already set, use ChangePIN
+var ErrPINAlreadySet = errors.New("pin already set")
+
+//ErrPINNotSet -- PIN is not set for account.
+//Authentication returns ErrAuthFailed instead, so accounts without PIN can not be found out.
+var ErrPINNotSet = errors.New("pin is not set")
+
+//ErrAuthFailed -- wrong account or PIN
+var ErrAuthFailed = errors.New("authentication failed")
+
+//ErrAccountLocked -- account locked after wrong PINs
+var ErrAccountLocked = errors.New("account is locked")
+
+//ErrSessionNotFound -- session not found
+var ErrSessionNotFound = errors.New("session not found")
+
+//ErrSessionExpired -- session expired
+var ErrSessionExpired = errors.New("session expired")
+
+//ErrForbidden -- operation is not allowed for caller
+var ErrForbidden = errors.New("forbidden")
+
+const (
+	pinIterations      = 100_000
+	pinMaxFailures     = 5
+	pinLockout         = 15 * time.Minute
+	defaultSessionTTL  = 30 * time.Minute
+	pinSaltSize        = 16
+	sessionTokenLength = 32
+)
+
+type credential struct {
+	salt        []byte
+	hash        []byte
+	failures    int
+	lockedUntil time.Time
+}
+
+type session struct {
+	accountID int64
+	expiresAt time.Time
+}
+
+//SetSessionTTL sets how long session token lives
+func (s *Service) SetSessionTTL(ttl time.Duration) {
+	s.sessionTTL = ttl
+}
+
+//SetPIN sets first PIN of account
+func (s *Service) SetPIN(accountID int64, pin string) error {
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.credentials[accountID]; ok {
+		return ErrPINAlreadySet
+	}
+	return s.storePIN(accountID, pin)
+}
+
+//ChangePIN sets new PIN if old one is right
+func (s *Service) ChangePIN(accountID int64, oldPIN string, newPIN string) error {
+
+	err := s.checkPIN(accountID, oldPIN)
+	if err != nil {
+		return err
+	}
+	return s.storePIN(accountID, newPIN)
+}
+
+//Authenticate checks PIN and returns session token
+func (s *Service) Authenticate(accountID int64, pin string) (string, error) {
+
+	err := s.checkPIN(accountID, pin)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, sessionTokenLength)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	ttl := s.sessionTTL
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	if s.sessions == nil {
+		s.sessions = make(map[string]*session)
+	}
+	s.purgeSessions()
+	s.sessions[token] = &session{
+		accountID: accountID,
+		expiresAt: s.currentTime().Add(ttl),
+	}
+	return token, nil
+}
+
+//Logout removes session
+func (s *Service) Logout(token string) {
+	delete(s.sessions, token)
+}
+
+//Session returns facade which allows operations only on session account.
+//Token is checked again on every call, so facade stops working after Logout or expiry.
+func (s *Service) Session(token string) (*Session, error) {
+
+	session, err := s.findSession(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{svc: s, token: token, accountID: session.accountID}, nil
+}
+
+func (s *Service) findSession(token string) (*session, error) {
+
+	session, ok := s.sessions[token]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if !s.currentTime().Before(session.expiresAt) {
+		delete(s.sessions, token)
+		return nil, ErrSessionExpired
+	}
+	return session, nil
+}
+
+//purgeSessions removes expired sessions, so tokens which are never used again do not pile up
+func (s *Service) purgeSessions() {
+
+	now := s.currentTime()
+	for token, v := range s.sessions {
+		if !now.Before(v.expiresAt) {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+func (s *Service) storePIN(accountID int64, pin string) error {
+
+	if len(pin) < 4 || len(pin) > 6 {
+		return ErrPINInvalid
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return ErrPINInvalid
+		}
+	}
+
+	salt := make([]byte, pinSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return err
+	}
+
+	if s.credentials == nil {
+		s.credentials = make(map[int64]*credential)
+	}
+	s.credentials[accountID] = &credential{
+		salt: salt,
+		hash: hashPIN(pin, salt),
+	}
+	return nil
+}
+
+func (s *Service) checkPIN(accountID int64, pin string) error {
+
+	cred, ok := s.credentials[accountID]
+	if _, err := s.FindAccountByID(accountID); err != nil || !ok {
+		// PIN is hashed anyway, so unknown account and account without PIN take as long as wrong PIN
+		hashPIN(pin, make([]byte, pinSaltSize))
+		return ErrAuthFailed
+	}
+
+	now := s.currentTime()
+	if now.Before(cred.lockedUntil) {
+		return ErrAccountLocked
+	}
+	if subtle.ConstantTimeCompare(cred.hash, hashPIN(pin, cred.salt)) != 1 {
+		cred.failures++
+		if cred.failures >= pinMaxFailures {
+			cred.failures = 0
+			cred.lockedUntil = now.Add(pinLockout)
+			return ErrAccountLocked
+		}
+		return ErrAuthFailed
+	}
+
+	cred.failures = 0
+	return nil
+}
+
+//hashPIN is PBKDF2 with HMAC-SHA256 and one output block
+func hashPIN(pin string, salt []byte) []byte {
+
+	mac := hmac.New(sha256.New, []byte(pin))
+	block := make([]byte, 4)
+	binary.BigEndian.PutUint32(block, 1)
+	mac.Write(salt)
+	mac.Write(block)
+	u := mac.Sum(nil)
+
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < pinIterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+//Session is authenticated facade over Service for one account, it returns copies, so caller can not change stored data
+type Session struct {
+	svc       *Service
+	token     string
+	accountID int64
+}
+
+//check fails when session was closed by Logout or expired
+func (a *Session) check() error {
+
+	_, err := a.svc.findSession(a.token)
+	return err
+}
+
+func (a *Session) actor() types.Actor {
+	return types.Actor{Name: fmt.Sprint("account:", a.accountID), Role: types.RoleCustomer, AccountID: a.accountID}
+}
+
+//AccountID returns account of session
+func (a *Session) AccountID() int64 {
+	return a.accountID
+}
+
+//Account method
+func (a *Session) Account() (*types.Account, error) {
+
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	return copyAccount(a.svc.FindAccountByID(a.accountID))
+}
+
+//Pay method
+func (a *Session) Pay(amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor())()
+
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.Pay(a.accountID, amount, category))
+}
+
+//FindPaymentByID returns payment only if it belongs to session account
+func (a *Session) FindPaymentByID(paymentID string) (*types.Payment, error) {
+
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	payment, err := a.svc.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.AccountID != a.accountID {
+		return nil, ErrForbidden
+	}
+	return copyPayment(payment, nil)
+}
+
+//Repeat method
+func (a *Session) Repeat(paymentID string) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor())()
+
+	_, err := a.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.Repeat(paymentID))
+}
+
+//FavoritePayment method
+func (a *Session) FavoritePayment(paymentID string, name string) (*types.Favorite, error) {
+	defer a.svc.actingAs(a.actor())()
+
+	_, err := a.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return copyFavorite(a.svc.FavoritePayment(paymentID, name))
+}
+
+//PayFromFavorite method
+func (a *Session) PayFromFavorite(favoriteID string) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor())()
+
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	_, err := a.svc.FindFavoriteByID(a.accountID, favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.PayFromFavorite(favoriteID))
+}
+
+//Favorites method
+func (a *Session) Favorites() ([]types.Favorite, error) {
+
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	return a.svc.AccountFavorites(a.accountID)
+}
+
+//History method
+func (a *Session) History() ([]types.Payment, error) {
+
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	return a.svc.ExportAccountHistory(a.accountID)
+}
+
+//copyAccount returns copy of account returned with err, so facade callers can not change stored account
+func copyAccount(account *types.Account, err error) (*types.Account, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	copied := *account
+	return &copied, nil
+}
+
+//copyPayment is copyAccount for payment
+func copyPayment(payment *types.Payment, err error) (*types.Payment, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	copied := *payment
+	return &copied, nil
+}
+
+//copyFavorite is copyAccount for favorite
+func copyFavorite(favorite *types.Favorite, err error) (*types.Favorite, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	copied := *favorite
+	return &copied, nil
+}
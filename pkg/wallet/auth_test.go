@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestService_Authenticate_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	other, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(account.ID, 100_00)
+	svc.Deposit(other.ID, 100_00)
+	otherPayment, _ := svc.Pay(other.ID, 1_00, "Cafe")
+
+	err := svc.SetPIN(account.ID, "12a4")
+	if !errors.Is(err, ErrPINInvalid) {
+		t.Errorf("method SetPIN returned wrong error, error => %v", err)
+	}
+	err = svc.SetPIN(account.ID, "1234")
+	if err != nil {
+		t.Fatalf("method SetPIN returned not nil error, error => %v", err)
+	}
+
+	_, err = svc.Authenticate(account.ID, "0000")
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("method Authenticate returned wrong error, error => %v", err)
+	}
+
+	token, err := svc.Authenticate(account.ID, "1234")
+	if err != nil {
+		t.Fatalf("method Authenticate returned not nil error, error => %v", err)
+	}
+	session, err := svc.Session(token)
+	if err != nil {
+		t.Fatalf("method Session returned not nil error, error => %v", err)
+	}
+
+	payment, err := session.Pay(10_00, "Cafe")
+	if err != nil || payment.AccountID != account.ID {
+		t.Errorf("method Pay returned wrong payment => %v, error => %v", payment, err)
+	}
+	_, err = session.Repeat(otherPayment.ID)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("method Repeat returned wrong error, error => %v", err)
+	}
+
+	clock.now = clock.now.Add(defaultSessionTTL)
+	_, err = svc.Session(token)
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("method Session returned wrong error, error => %v", err)
+	}
+}
+
+func TestService_Authenticate_lockout_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.SetPIN(account.ID, "1234")
+
+	var err error
+	for i := 0; i < pinMaxFailures; i++ {
+		_, err = svc.Authenticate(account.ID, "0000")
+	}
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("account not locked, error => %v", err)
+	}
+	_, err = svc.Authenticate(account.ID, "1234")
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("locked account authenticated, error => %v", err)
+	}
+
+	clock.now = clock.now.Add(pinLockout)
+	_, err = svc.Authenticate(account.ID, "1234")
+	if err != nil {
+		t.Errorf("method Authenticate returned not nil error, error => %v", err)
+	}
+}
+
+func TestService_Session_revalidated_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.SetPIN(account.ID, "1234")
+
+	token, _ := svc.Authenticate(account.ID, "1234")
+	session, err := svc.Session(token)
+	if err != nil {
+		t.Fatalf("method Session returned not nil error, error => %v", err)
+	}
+	svc.Logout(token)
+	_, err = session.Pay(10_00, "Cafe")
+	if !errors.Is(err, ErrSessionNotFound) || account.Balance != 100_00 {
+		t.Errorf("session works after Logout, error => %v", err)
+	}
+
+	token, _ = svc.Authenticate(account.ID, "1234")
+	session, _ = svc.Session(token)
+	clock.now = clock.now.Add(defaultSessionTTL)
+	_, err = session.History()
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("session works after expiry, error => %v", err)
+	}
+}
+
+func TestService_Session_returnsCopies_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.SetPIN(account.ID, "1234")
+	token, _ := svc.Authenticate(account.ID, "1234")
+	session, _ := svc.Session(token)
+
+	got, _ := session.Account()
+	got.Balance = 999_999
+	payment, _ := session.Pay(10_00, "Cafe")
+	payment.Amount = 1_000_000
+	found, _ := session.FindPaymentByID(payment.ID)
+	found.Amount = 1_000_000
+	repeated, _ := session.Repeat(payment.ID)
+	repeated.Amount = 1_000_000
+	favorite, _ := session.FavoritePayment(payment.ID, "Coffee")
+	favorite.Amount = 1_000_000
+	fromFavorite, _ := session.PayFromFavorite(favorite.ID)
+	fromFavorite.Amount = 1_000_000
+
+	if account.Balance != 70_00 {
+		t.Errorf("session changed balance => %v", account.Balance)
+	}
+	for _, v := range svc.payments {
+		if v.Amount != 10_00 {
+			t.Errorf("session changed payment => %v", v)
+		}
+	}
+	if svc.favorites[0].Amount != 10_00 {
+		t.Errorf("session changed favorite => %v", svc.favorites[0])
+	}
+}
+
+func TestService_Authenticate_purgesExpired_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.SetPIN(account.ID, "1234")
+	svc.Authenticate(account.ID, "1234")
+	svc.Authenticate(account.ID, "1234")
+
+	clock.now = clock.now.Add(defaultSessionTTL)
+	svc.Authenticate(account.ID, "1234")
+	if len(svc.sessions) != 1 {
+		t.Errorf("expired sessions were not purged => %d", len(svc.sessions))
+	}
+}
+
+func TestService_Authenticate_noPIN_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	_, err := svc.Authenticate(account.ID, "1234")
+	if err != ErrAuthFailed {
+		t.Errorf("account without PIN returned wrong error, error => %v", err)
+	}
+	_, err = svc.Authenticate(account.ID+1, "1234")
+	if err != ErrAuthFailed {
+		t.Errorf("unknown account returned wrong error, error => %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrBatchAborted -- batch was not applied because one of items failed
+var ErrBatchAborted = errors.New("batch aborted")
+
+//ErrBatchModeInvalid -- unknown batch mode
+var ErrBatchModeInvalid = errors.New("batch mode is invalid")
+
+//BatchResult is result of one batch item, Payment is nil when Err is not nil
+type BatchResult struct {
+	Payment *types.Payment
+	Err     error
+}
+
+//batchChange keeps account snapshots around payment of item for its audit record
+type batchChange struct {
+	before interface{}
+	after  interface{}
+}
+
+//PayBatch pays items using goroutines, 0 means runtime.NumCPU(), items of one account are paid in their order.
+//In all-or-nothing mode nothing is applied if any item fails and ErrBatchAborted is returned.
+func (s *Service) PayBatch(items []types.BatchItem, mode types.BatchMode, goroutines int) ([]BatchResult, error) {
+	return s.payBatch(newTracker(context.Background(), "pay batch", len(items)), items, mode, goroutines)
+}
+
+func (s *Service) payBatch(t *tracker, items []types.BatchItem, mode types.BatchMode, goroutines int) ([]BatchResult, error) {
+
+	if mode != types.BatchAllOrNothing && mode != types.BatchBestEffort {
+		return nil, ErrBatchModeInvalid
+	}
+
+	var order []int64
+	groups := make(map[int64][]int)
+	for i, item := range items {
+		if _, ok := groups[item.AccountID]; !ok {
+			order = append(order, item.AccountID)
+		}
+		groups[item.AccountID] = append(groups[item.AccountID], i)
+	}
+
+	if goroutines < 1 {
+		goroutines = runtime.NumCPU()
+	}
+	if goroutines > len(order) {
+		goroutines = len(order)
+	}
+
+	results := make([]BatchResult, len(items))
+	changes := make([]batchChange, len(items))
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(accounts []int64) {
+			defer wg.Done()
+			for _, accountID := range accounts {
+				for _, index := range groups[accountID] {
+					if err := t.check(); err != nil {
+						results[index] = BatchResult{Err: err}
+						continue
+					}
+					item := items[index]
+					// account is changed only by this worker, so snapshots are exact
+					before := s.accountSnapshot(item.AccountID)
+					payment, err := s.newPayment(item.AccountID, item.Amount, item.Category)
+					results[index] = BatchResult{Payment: payment, Err: err}
+					changes[index] = batchChange{before: before, after: s.accountSnapshot(item.AccountID)}
+					t.add(1)
+				}
+			}
+		}(order[i*len(order)/goroutines : (i+1)*len(order)/goroutines])
+	}
+	wg.Wait()
+
+	if err := t.check(); err != nil {
+		// cancelled batch is rolled back whatever the mode is
+		for i, v := range results {
+			if v.Err == nil {
+				account, findErr := s.FindAccountByID(v.Payment.AccountID)
+				if findErr == nil {
+					account.Balance += v.Payment.Amount
+				}
+			}
+			results[i] = BatchResult{Err: err}
+		}
+		return results, err
+	}
+
+	failed := false
+	for _, v := range results {
+		if v.Err != nil {
+			failed = true
+			break
+		}
+	}
+
+	if failed && mode == types.BatchAllOrNothing {
+		for i, v := range results {
+			if v.Err != nil {
+				continue
+			}
+			account, err := s.FindAccountByID(v.Payment.AccountID)
+			if err == nil {
+				account.Balance += v.Payment.Amount
+			}
+			results[i] = BatchResult{Err: ErrBatchAborted}
+		}
+		return results, ErrBatchAborted
+	}
+
+	for i, v := range results {
+		if v.Err == nil {
+			s.addPaymentChange(v.Payment, changes[i].before, changes[i].after)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestService_PayBatch_bestEffort_user(t *testing.T) {
+	var svc Service
+
+	first, _ := svc.RegisterAccount("+992000000001")
+	second, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(first.ID, 10_00)
+	svc.Deposit(second.ID, 5_00)
+
+	items := []types.BatchItem{
+		{AccountID: first.ID, Amount: 6_00, Category: "Salary"},
+		{AccountID: second.ID, Amount: 5_00, Category: "Salary"},
+		{AccountID: first.ID, Amount: 6_00, Category: "Salary"},
+		{AccountID: first.ID, Amount: 4_00, Category: "Salary"},
+		{AccountID: 3, Amount: 1_00, Category: "Salary"},
+	}
+	results, err := svc.PayBatch(items, types.BatchBestEffort, 2)
+	if err != nil {
+		t.Fatalf("method PayBatch returned not nil error, error => %v", err)
+	}
+
+	wantErr := []error{nil, nil, ErrNotEnoughtBalance, nil, ErrAccountNotFound}
+	for i, v := range results {
+		if !errors.Is(v.Err, wantErr[i]) {
+			t.Errorf("item %d wrong error, want => %v got => %v", i, wantErr[i], v.Err)
+		}
+	}
+	if first.Balance != 0 || second.Balance != 0 {
+		t.Errorf("wrong balances => %v %v", first.Balance, second.Balance)
+	}
+	if len(svc.payments) != 3 {
+		t.Errorf("wrong payments count => %v", len(svc.payments))
+	}
+}
+
+func TestService_PayBatch_allOrNothing_user(t *testing.T) {
+	var svc Service
+
+	first, _ := svc.RegisterAccount("+992000000001")
+	second, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(first.ID, 10_00)
+	svc.Deposit(second.ID, 5_00)
+
+	items := []types.BatchItem{
+		{AccountID: first.ID, Amount: 6_00, Category: "Salary"},
+		{AccountID: second.ID, Amount: 6_00, Category: "Salary"},
+	}
+	results, err := svc.PayBatch(items, types.BatchAllOrNothing, 4)
+	if !errors.Is(err, ErrBatchAborted) {
+		t.Fatalf("method PayBatch returned wrong error, error => %v", err)
+	}
+	if !errors.Is(results[0].Err, ErrBatchAborted) || !errors.Is(results[1].Err, ErrNotEnoughtBalance) {
+		t.Errorf("wrong results => %v", results)
+	}
+	if first.Balance != 10_00 || second.Balance != 5_00 || len(svc.payments) != 0 {
+		t.Errorf("batch was partially applied")
+	}
+
+	items[1].Amount = 5_00
+	_, err = svc.PayBatch(items, types.BatchAllOrNothing, 4)
+	if err != nil {
+		t.Errorf("method PayBatch returned not nil error, error => %v", err)
+	}
+	if len(svc.payments) != 2 {
+		t.Errorf("wrong payments count => %v", len(svc.payments))
+	}
+}
+
+func TestService_PayBatch_auditsBalances_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 10_00)
+
+	items := []types.BatchItem{
+		{AccountID: account.ID, Amount: 3_00, Category: "Salary"},
+		{AccountID: account.ID, Amount: 2_00, Category: "Salary"},
+	}
+	_, err := svc.PayBatch(items, types.BatchAllOrNothing, 0)
+	if err != nil {
+		t.Fatalf("method PayBatch returned not nil error, error => %v", err)
+	}
+
+	records := svc.AuditLog()
+	paid := records[len(records)-2:]
+	wantBefore := []string{`"balance":1000`, `"balance":700`}
+	wantAfter := []string{`"balance":700`, `"balance":500`}
+	for i, v := range paid {
+		if v.Action != "payment.pay" || !strings.Contains(v.Before, wantBefore[i]) || !strings.Contains(v.After, wantAfter[i]) {
+			t.Errorf("wrong audit of item %d => %+v", i, v)
+		}
+	}
+}
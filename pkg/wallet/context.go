@@ -0,0 +1,603 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//checkEvery is how many payments workers handle between checks of ctx
+const checkEvery = 1024
+
+//CancelError is returned by Context methods when ctx is done before work is finished.
+//errors.Is matches it with context.Canceled or context.DeadlineExceeded.
+type CancelError struct {
+	Op    string
+	Done  int
+	Total int
+	Err   error
+}
+
+//Error method
+func (e *CancelError) Error() string {
+	return fmt.Sprintf("%s stopped after %d of %d: %v", e.Op, e.Done, e.Total, e.Err)
+}
+
+//Unwrap returns ctx.Err()
+func (e *CancelError) Unwrap() error {
+	return e.Err
+}
+
+//checkContext returns CancelError of op when ctx is done, it is used by methods which do not block
+func checkContext(ctx context.Context, op string) error {
+
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	return &CancelError{Op: op, Err: err}
+}
+
+//tracker counts processed items of operation and checks its ctx, it is safe for concurrent use
+type tracker struct {
+	ctx     context.Context
+	op      string
+	total   int
+	done    int64
+	matched int64
+	result  int64
+
+	mu       sync.Mutex
+	options  *ProgressOptions
+	start    time.Time
+	last     time.Time
+	finished bool
+}
+
+func newTracker(ctx context.Context, op string, total int) *tracker {
+	return &tracker{ctx: ctx, op: op, total: total}
+}
+
+//check returns CancelError when ctx is done
+func (t *tracker) check() error {
+
+	err := t.ctx.Err()
+	if err == nil {
+		return nil
+	}
+	return &CancelError{Op: t.op, Done: int(atomic.LoadInt64(&t.done)), Total: t.total, Err: err}
+}
+
+func (t *tracker) add(n int) {
+
+	atomic.AddInt64(&t.done, int64(n))
+	if t.options != nil {
+		t.report(false, nil)
+	}
+}
+
+//addResult counts payments kept by query and their amount, it is called before add of same items
+func (t *tracker) addResult(matched int, amount types.Money) {
+
+	atomic.AddInt64(&t.matched, int64(matched))
+	atomic.AddInt64(&t.result, int64(amount))
+}
+
+//ExportContext is Export which stops when ctx is done, files are written only after all dumps are built
+func (s *Service) ExportContext(ctx context.Context, dir string) error {
+	return s.exportDir(newTracker(ctx, "export", 0), dir)
+}
+
+//ImportContext is Import which stops when ctx is done, nothing is imported then
+func (s *Service) ImportContext(ctx context.Context, dir string) error {
+	return s.importWith(newTracker(ctx, "import", 0), dir)
+}
+
+//HistoryToFilesContext is HistoryToFiles which stops when ctx is done, nothing is written then
+func (s *Service) HistoryToFilesContext(ctx context.Context, payments []types.Payment, dir string, records int) error {
+	return s.historyToFiles(newTracker(ctx, "history to files", len(payments)), payments, dir, records)
+}
+
+//SumPaymentsContext is SumPayments which stops workers when ctx is done
+func (s *Service) SumPaymentsContext(ctx context.Context, goroutines int) (types.Money, error) {
+	return s.query("sum payments", goroutines).Sum(ctx)
+}
+
+//FilterPaymentsContext is FilterPayments which stops workers when ctx is done
+func (s *Service) FilterPaymentsContext(ctx context.Context, accountID int64, goroutines int) ([]types.Payment, error) {
+
+	return s.FilterPaymentsByFnContext(ctx, func(payment types.Payment) bool {
+		return payment.AccountID == accountID
+	}, goroutines)
+}
+
+//FilterPaymentsByFnContext is FilterPaymentsByFn which stops workers when ctx is done
+func (s *Service) FilterPaymentsByFnContext(ctx context.Context, filter func(payment types.Payment) bool, goroutines int) ([]types.Payment, error) {
+	return s.query("filter payments", goroutines).Filter(filter).Collect(ctx)
+}
+
+//PayBatchContext is PayBatch which stops workers when ctx is done, cancelled batch is rolled back in any mode
+func (s *Service) PayBatchContext(ctx context.Context, items []types.BatchItem, mode types.BatchMode, goroutines int) ([]BatchResult, error) {
+	return s.payBatch(newTracker(ctx, "pay batch", len(items)), items, mode, goroutines)
+}
+
+//RelayOutboxContext is RelayOutbox which stops before next event when ctx is done
+func (s *Service) RelayOutboxContext(ctx context.Context, sink EventSink) (int, error) {
+	return s.relayOutbox(newTracker(ctx, "relay outbox", 0), sink, noLocker{})
+}
+
+//DeliverWebhooksContext is DeliverWebhooks which sends requests with ctx, so its deadline limits them
+func (s *Service) DeliverWebhooksContext(ctx context.Context, client *http.Client) (int, error) {
+	return s.deliverWebhooks(newTracker(ctx, "deliver webhooks", 0), client, noLocker{})
+}
+
+//RunSchedulesContext is RunSchedules which stops before next schedule when ctx is done
+func (s *Service) RunSchedulesContext(ctx context.Context) ([]types.ScheduleExecution, error) {
+	return s.runSchedules(newTracker(ctx, "run schedules", 0))
+}
+
+// methods below do not block, so ctx is checked only before they start
+
+//SetPINContext is SetPIN which fails with CancelError when ctx is done
+func (s *Service) SetPINContext(ctx context.Context, accountID int64, pin string) error {
+
+	if err := checkContext(ctx, "set PIN"); err != nil {
+		return err
+	}
+	return s.SetPIN(accountID, pin)
+}
+
+//ChangePINContext is ChangePIN which fails with CancelError when ctx is done
+func (s *Service) ChangePINContext(ctx context.Context, accountID int64, oldPIN string, newPIN string) error {
+
+	if err := checkContext(ctx, "change PIN"); err != nil {
+		return err
+	}
+	return s.ChangePIN(accountID, oldPIN, newPIN)
+}
+
+//AuthenticateContext is Authenticate which fails with CancelError when ctx is done
+func (s *Service) AuthenticateContext(ctx context.Context, accountID int64, pin string) (string, error) {
+
+	if err := checkContext(ctx, "authenticate"); err != nil {
+		return "", err
+	}
+	return s.Authenticate(accountID, pin)
+}
+
+//SessionContext is Session which fails with CancelError when ctx is done
+func (s *Service) SessionContext(ctx context.Context, token string) (*Session, error) {
+
+	if err := checkContext(ctx, "session"); err != nil {
+		return nil, err
+	}
+	return s.Session(token)
+}
+
+//AccountFavoritesContext is AccountFavorites which fails with CancelError when ctx is done
+func (s *Service) AccountFavoritesContext(ctx context.Context, accountID int64) ([]types.Favorite, error) {
+
+	if err := checkContext(ctx, "account favorites"); err != nil {
+		return nil, err
+	}
+	return s.AccountFavorites(accountID)
+}
+
+//FindFavoriteByIDContext is FindFavoriteByID which fails with CancelError when ctx is done
+func (s *Service) FindFavoriteByIDContext(ctx context.Context, accountID int64, favoriteID string) (*types.Favorite, error) {
+
+	if err := checkContext(ctx, "find favorite by ID"); err != nil {
+		return nil, err
+	}
+	return s.FindFavoriteByID(accountID, favoriteID)
+}
+
+//UpdateFavoriteContext is UpdateFavorite which fails with CancelError when ctx is done
+func (s *Service) UpdateFavoriteContext(ctx context.Context, accountID int64, favoriteID string, name string, amount types.Money) (*types.Favorite, error) {
+
+	if err := checkContext(ctx, "update favorite"); err != nil {
+		return nil, err
+	}
+	return s.UpdateFavorite(accountID, favoriteID, name, amount)
+}
+
+//DeleteFavoriteContext is DeleteFavorite which fails with CancelError when ctx is done
+func (s *Service) DeleteFavoriteContext(ctx context.Context, accountID int64, favoriteID string) error {
+
+	if err := checkContext(ctx, "delete favorite"); err != nil {
+		return err
+	}
+	return s.DeleteFavorite(accountID, favoriteID)
+}
+
+//FreezeAccountContext is FreezeAccount which fails with CancelError when ctx is done
+func (s *Service) FreezeAccountContext(ctx context.Context, accountID int64) error {
+
+	if err := checkContext(ctx, "freeze account"); err != nil {
+		return err
+	}
+	return s.FreezeAccount(accountID)
+}
+
+//UnfreezeAccountContext is UnfreezeAccount which fails with CancelError when ctx is done
+func (s *Service) UnfreezeAccountContext(ctx context.Context, accountID int64) error {
+
+	if err := checkContext(ctx, "unfreeze account"); err != nil {
+		return err
+	}
+	return s.UnfreezeAccount(accountID)
+}
+
+//CloseAccountContext is CloseAccount which fails with CancelError when ctx is done
+func (s *Service) CloseAccountContext(ctx context.Context, accountID int64, sweepToID int64) error {
+
+	if err := checkContext(ctx, "close account"); err != nil {
+		return err
+	}
+	return s.CloseAccount(accountID, sweepToID)
+}
+
+//ChangePhoneContext is ChangePhone which fails with CancelError when ctx is done
+func (s *Service) ChangePhoneContext(ctx context.Context, accountID int64, newPhone types.Phone) error {
+
+	if err := checkContext(ctx, "change phone"); err != nil {
+		return err
+	}
+	return s.ChangePhone(accountID, newPhone)
+}
+
+//RequestPhoneChangeContext is RequestPhoneChange which fails with CancelError when ctx is done
+func (s *Service) RequestPhoneChangeContext(ctx context.Context, accountID int64, newPhone types.Phone) error {
+
+	if err := checkContext(ctx, "request phone change"); err != nil {
+		return err
+	}
+	return s.RequestPhoneChange(accountID, newPhone)
+}
+
+//ConfirmPhoneChangeContext is ConfirmPhoneChange which fails with CancelError when ctx is done
+func (s *Service) ConfirmPhoneChangeContext(ctx context.Context, accountID int64, code string) error {
+
+	if err := checkContext(ctx, "confirm phone change"); err != nil {
+		return err
+	}
+	return s.ConfirmPhoneChange(accountID, code)
+}
+
+//PhoneHistoryContext is PhoneHistory which fails with CancelError when ctx is done
+func (s *Service) PhoneHistoryContext(ctx context.Context, accountID int64) ([]types.PhoneChange, error) {
+
+	if err := checkContext(ctx, "phone history"); err != nil {
+		return nil, err
+	}
+	return s.PhoneHistory(accountID)
+}
+
+//FindAccountByPhoneContext is FindAccountByPhone which fails with CancelError when ctx is done
+func (s *Service) FindAccountByPhoneContext(ctx context.Context, phone types.Phone) (*types.Account, error) {
+
+	if err := checkContext(ctx, "find account by phone"); err != nil {
+		return nil, err
+	}
+	return s.FindAccountByPhone(phone)
+}
+
+//RequestMoneyContext is RequestMoney which fails with CancelError when ctx is done
+func (s *Service) RequestMoneyContext(ctx context.Context, accountID int64, category types.PaymentCategory, shares []types.MoneyRequestShare, ttl time.Duration) (*types.MoneyRequest, error) {
+
+	if err := checkContext(ctx, "request money"); err != nil {
+		return nil, err
+	}
+	return s.RequestMoney(accountID, category, shares, ttl)
+}
+
+//FindMoneyRequestContext is FindMoneyRequest which fails with CancelError when ctx is done
+func (s *Service) FindMoneyRequestContext(ctx context.Context, accountID int64, requestID string) (*types.MoneyRequest, error) {
+
+	if err := checkContext(ctx, "find money request"); err != nil {
+		return nil, err
+	}
+	return s.FindMoneyRequest(accountID, requestID)
+}
+
+//IncomingMoneyRequestsContext is IncomingMoneyRequests which fails with CancelError when ctx is done
+func (s *Service) IncomingMoneyRequestsContext(ctx context.Context, accountID int64) ([]types.MoneyRequest, error) {
+
+	if err := checkContext(ctx, "incoming money requests"); err != nil {
+		return nil, err
+	}
+	return s.IncomingMoneyRequests(accountID)
+}
+
+//AcceptMoneyRequestContext is AcceptMoneyRequest which fails with CancelError when ctx is done
+func (s *Service) AcceptMoneyRequestContext(ctx context.Context, accountID int64, requestID string) (*types.Payment, error) {
+
+	if err := checkContext(ctx, "accept money request"); err != nil {
+		return nil, err
+	}
+	return s.AcceptMoneyRequest(accountID, requestID)
+}
+
+//DeclineMoneyRequestContext is DeclineMoneyRequest which fails with CancelError when ctx is done
+func (s *Service) DeclineMoneyRequestContext(ctx context.Context, accountID int64, requestID string) error {
+
+	if err := checkContext(ctx, "decline money request"); err != nil {
+		return err
+	}
+	return s.DeclineMoneyRequest(accountID, requestID)
+}
+
+//AddRewardRuleContext is AddRewardRule which fails with CancelError when ctx is done
+func (s *Service) AddRewardRuleContext(ctx context.Context, category types.PaymentCategory, kind types.RewardKind, percent int64, monthlyCap types.Money) (*types.RewardRule, error) {
+
+	if err := checkContext(ctx, "add reward rule"); err != nil {
+		return nil, err
+	}
+	return s.AddRewardRule(category, kind, percent, monthlyCap)
+}
+
+//ConfirmContext is Confirm which fails with CancelError when ctx is done
+func (s *Service) ConfirmContext(ctx context.Context, paymentID string) error {
+
+	if err := checkContext(ctx, "confirm"); err != nil {
+		return err
+	}
+	return s.Confirm(paymentID)
+}
+
+//PointsContext is Points which fails with CancelError when ctx is done
+func (s *Service) PointsContext(ctx context.Context, accountID int64) (types.Money, error) {
+
+	if err := checkContext(ctx, "points"); err != nil {
+		return 0, err
+	}
+	return s.Points(accountID)
+}
+
+//RedeemPointsContext is RedeemPoints which fails with CancelError when ctx is done
+func (s *Service) RedeemPointsContext(ctx context.Context, accountID int64, points types.Money) error {
+
+	if err := checkContext(ctx, "redeem points"); err != nil {
+		return err
+	}
+	return s.RedeemPoints(accountID, points)
+}
+
+//AccountRewardsContext is AccountRewards which fails with CancelError when ctx is done
+func (s *Service) AccountRewardsContext(ctx context.Context, accountID int64) ([]types.Reward, error) {
+
+	if err := checkContext(ctx, "account rewards"); err != nil {
+		return nil, err
+	}
+	return s.AccountRewards(accountID)
+}
+
+//ScheduleFavoriteContext is ScheduleFavorite which fails with CancelError when ctx is done
+func (s *Service) ScheduleFavoriteContext(ctx context.Context, favoriteID string, kind types.ScheduleKind, start time.Time) (*types.Schedule, error) {
+
+	if err := checkContext(ctx, "schedule favorite"); err != nil {
+		return nil, err
+	}
+	return s.ScheduleFavorite(favoriteID, kind, start)
+}
+
+//FindScheduleByIDContext is FindScheduleByID which fails with CancelError when ctx is done
+func (s *Service) FindScheduleByIDContext(ctx context.Context, scheduleID string) (*types.Schedule, error) {
+
+	if err := checkContext(ctx, "find schedule by ID"); err != nil {
+		return nil, err
+	}
+	return s.FindScheduleByID(scheduleID)
+}
+
+//SetScheduleRetryContext is SetScheduleRetry which fails with CancelError when ctx is done
+func (s *Service) SetScheduleRetryContext(ctx context.Context, scheduleID string, maxRetries int, interval time.Duration) error {
+
+	if err := checkContext(ctx, "set schedule retry"); err != nil {
+		return err
+	}
+	return s.SetScheduleRetry(scheduleID, maxRetries, interval)
+}
+
+//PauseScheduleContext is PauseSchedule which fails with CancelError when ctx is done
+func (s *Service) PauseScheduleContext(ctx context.Context, scheduleID string) error {
+
+	if err := checkContext(ctx, "pause schedule"); err != nil {
+		return err
+	}
+	return s.PauseSchedule(scheduleID)
+}
+
+//ResumeScheduleContext is ResumeSchedule which fails with CancelError when ctx is done
+func (s *Service) ResumeScheduleContext(ctx context.Context, scheduleID string) error {
+
+	if err := checkContext(ctx, "resume schedule"); err != nil {
+		return err
+	}
+	return s.ResumeSchedule(scheduleID)
+}
+
+//ScheduleHistoryContext is ScheduleHistory which fails with CancelError when ctx is done
+func (s *Service) ScheduleHistoryContext(ctx context.Context, scheduleID string) ([]types.ScheduleExecution, error) {
+
+	if err := checkContext(ctx, "schedule history"); err != nil {
+		return nil, err
+	}
+	return s.ScheduleHistory(scheduleID)
+}
+
+//RegisterAccountContext is RegisterAccount which fails with CancelError when ctx is done
+func (s *Service) RegisterAccountContext(ctx context.Context, phone types.Phone) (*types.Account, error) {
+
+	if err := checkContext(ctx, "register account"); err != nil {
+		return nil, err
+	}
+	return s.RegisterAccount(phone)
+}
+
+//PayContext is Pay which fails with CancelError when ctx is done
+func (s *Service) PayContext(ctx context.Context, accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+
+	if err := checkContext(ctx, "pay"); err != nil {
+		return nil, err
+	}
+	return s.Pay(accountID, amount, category)
+}
+
+//FindAccountByIDContext is FindAccountByID which fails with CancelError when ctx is done
+func (s *Service) FindAccountByIDContext(ctx context.Context, accountID int64) (*types.Account, error) {
+
+	if err := checkContext(ctx, "find account by ID"); err != nil {
+		return nil, err
+	}
+	return s.FindAccountByID(accountID)
+}
+
+//DepositContext is Deposit which fails with CancelError when ctx is done
+func (s *Service) DepositContext(ctx context.Context, accountID int64, amount types.Money) error {
+
+	if err := checkContext(ctx, "deposit"); err != nil {
+		return err
+	}
+	return s.Deposit(accountID, amount)
+}
+
+//FindPaymentByIDContext is FindPaymentByID which fails with CancelError when ctx is done
+func (s *Service) FindPaymentByIDContext(ctx context.Context, paymentID string) (*types.Payment, error) {
+
+	if err := checkContext(ctx, "find payment by ID"); err != nil {
+		return nil, err
+	}
+	return s.FindPaymentByID(paymentID)
+}
+
+//RejectContext is Reject which fails with CancelError when ctx is done
+func (s *Service) RejectContext(ctx context.Context, paymentID string) error {
+
+	if err := checkContext(ctx, "reject"); err != nil {
+		return err
+	}
+	return s.Reject(paymentID)
+}
+
+//RepeatContext is Repeat which fails with CancelError when ctx is done
+func (s *Service) RepeatContext(ctx context.Context, paymentID string) (*types.Payment, error) {
+
+	if err := checkContext(ctx, "repeat"); err != nil {
+		return nil, err
+	}
+	return s.Repeat(paymentID)
+}
+
+//FavoritePaymentContext is FavoritePayment which fails with CancelError when ctx is done
+func (s *Service) FavoritePaymentContext(ctx context.Context, paymentID string, name string) (*types.Favorite, error) {
+
+	if err := checkContext(ctx, "favorite payment"); err != nil {
+		return nil, err
+	}
+	return s.FavoritePayment(paymentID, name)
+}
+
+//PayFromFavoriteContext is PayFromFavorite which fails with CancelError when ctx is done
+func (s *Service) PayFromFavoriteContext(ctx context.Context, favoriteID string) (*types.Payment, error) {
+
+	if err := checkContext(ctx, "pay from favorite"); err != nil {
+		return nil, err
+	}
+	return s.PayFromFavorite(favoriteID)
+}
+
+//ExportToFileContext is ExportToFile which fails with CancelError when ctx is done
+func (s *Service) ExportToFileContext(ctx context.Context, path string) error {
+
+	if err := checkContext(ctx, "export to file"); err != nil {
+		return err
+	}
+	return s.ExportToFile(path)
+}
+
+//ImportFromFileContext is ImportFromFile which fails with CancelError when ctx is done
+func (s *Service) ImportFromFileContext(ctx context.Context, path string) error {
+
+	if err := checkContext(ctx, "import from file"); err != nil {
+		return err
+	}
+	return s.ImportFromFile(path)
+}
+
+//ExportAccountHistoryContext is ExportAccountHistory which fails with CancelError when ctx is done
+func (s *Service) ExportAccountHistoryContext(ctx context.Context, accountID int64) ([]types.Payment, error) {
+
+	if err := checkContext(ctx, "export account history"); err != nil {
+		return nil, err
+	}
+	return s.ExportAccountHistory(accountID)
+}
+
+//SendVerificationCodeContext is SendVerificationCode which fails with CancelError when ctx is done
+func (s *Service) SendVerificationCodeContext(ctx context.Context, accountID int64) error {
+
+	if err := checkContext(ctx, "send verification code"); err != nil {
+		return err
+	}
+	return s.SendVerificationCode(accountID)
+}
+
+//VerifyAccountContext is VerifyAccount which fails with CancelError when ctx is done
+func (s *Service) VerifyAccountContext(ctx context.Context, accountID int64, code string) error {
+
+	if err := checkContext(ctx, "verify account"); err != nil {
+		return err
+	}
+	return s.VerifyAccount(accountID, code)
+}
+
+//IsVerifiedContext is IsVerified which fails with CancelError when ctx is done
+func (s *Service) IsVerifiedContext(ctx context.Context, accountID int64) (bool, error) {
+
+	if err := checkContext(ctx, "is verified"); err != nil {
+		return false, err
+	}
+	return s.IsVerified(accountID)
+}
+
+//SendPaymentCodeContext is SendPaymentCode which fails with CancelError when ctx is done
+func (s *Service) SendPaymentCodeContext(ctx context.Context, accountID int64, amount types.Money, category types.PaymentCategory) error {
+
+	if err := checkContext(ctx, "send payment code"); err != nil {
+		return err
+	}
+	return s.SendPaymentCode(accountID, amount, category)
+}
+
+//PayWithCodeContext is PayWithCode which fails with CancelError when ctx is done
+func (s *Service) PayWithCodeContext(ctx context.Context, accountID int64, amount types.Money, category types.PaymentCategory, code string) (*types.Payment, error) {
+
+	if err := checkContext(ctx, "pay with code"); err != nil {
+		return nil, err
+	}
+	return s.PayWithCode(accountID, amount, category, code)
+}
+
+//RegisterWebhookContext is RegisterWebhook which fails with CancelError when ctx is done
+func (s *Service) RegisterWebhookContext(ctx context.Context, endpoint string, secret string, accountID int64, category types.PaymentCategory) (*types.Webhook, error) {
+
+	if err := checkContext(ctx, "register webhook"); err != nil {
+		return nil, err
+	}
+	return s.RegisterWebhook(endpoint, secret, accountID, category)
+}
+
+//RemoveWebhookContext is RemoveWebhook which fails with CancelError when ctx is done
+func (s *Service) RemoveWebhookContext(ctx context.Context, webhookID string) error {
+
+	if err := checkContext(ctx, "remove webhook"); err != nil {
+		return err
+	}
+	return s.RemoveWebhook(webhookID)
+}
@@ -0,0 +1,217 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func newServiceWithPayments(t *testing.T, count int) *Service {
+	svc := &Service{}
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatalf("method RegisterAccount returned not nil error, error => %v", err)
+	}
+	svc.Deposit(account.ID, types.Money(count))
+	for i := 0; i < count; i++ {
+		_, err = svc.Pay(account.ID, 1, "Cafe")
+		if err != nil {
+			t.Fatalf("method Pay returned not nil error, error => %v", err)
+		}
+	}
+	return svc
+}
+
+func TestService_SumPaymentsContext_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 5000)
+
+	for _, goroutines := range []int{0, 1, 3, 10} {
+		sum, err := svc.SumPaymentsContext(context.Background(), goroutines)
+		if err != nil || sum != 5000 {
+			t.Errorf("goroutines %d: sum => %v, error => %v", goroutines, sum, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := svc.SumPaymentsContext(ctx, 4)
+	var cancelErr *CancelError
+	if !errors.Is(err, context.Canceled) || !errors.As(err, &cancelErr) {
+		t.Fatalf("cancelled sum returned wrong error => %v", err)
+	}
+	if cancelErr.Done != 0 || cancelErr.Total != 5000 {
+		t.Errorf("cancelled sum reported wrong progress => %v", cancelErr)
+	}
+}
+
+func TestService_FilterPaymentsByFnContext_stopsWorkers_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 5000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	_, err := svc.FilterPaymentsByFnContext(ctx, func(payment types.Payment) bool {
+		calls++
+		if calls == 10 {
+			cancel()
+		}
+		return true
+	}, 1)
+
+	var cancelErr *CancelError
+	if !errors.As(err, &cancelErr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled filter returned wrong error => %v", err)
+	}
+	if cancelErr.Done != checkEvery || calls != checkEvery {
+		t.Errorf("filter did not stop after first block, done => %d, calls => %d", cancelErr.Done, calls)
+	}
+
+	payments, err := svc.FilterPaymentsContext(context.Background(), 1, 3)
+	if err != nil || len(payments) != 5000 {
+		t.Fatalf("filter returned %d payments, error => %v", len(payments), err)
+	}
+	for i, v := range payments {
+		if v.ID != svc.payments[i].ID {
+			t.Fatalf("filter changed order of payments at %d", i)
+		}
+	}
+}
+
+func TestService_ExportImportContext_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 10)
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := svc.ExportContext(ctx, dir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled export returned wrong error => %v", err)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Errorf("cancelled export wrote %d files", len(files))
+	}
+
+	err = svc.ExportContext(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("method ExportContext returned not nil error, error => %v", err)
+	}
+
+	other := &Service{}
+	imported := 0
+	other.Subscribe(func(event types.Event) {
+		if event.Type == types.EventImported {
+			imported++
+		}
+	}, DeliverSync)
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	err = other.ImportContext(expired, dir)
+	var cancelErr *CancelError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &cancelErr) {
+		t.Fatalf("expired import returned wrong error => %v", err)
+	}
+	if cancelErr.Op != "import" || cancelErr.Total < 11 || imported != 0 {
+		t.Errorf("expired import => %v, imported events => %d", cancelErr, imported)
+	}
+
+	err = other.ImportContext(context.Background(), dir)
+	if err != nil || len(other.payments) != 10 || imported != 1 {
+		t.Errorf("import got %d payments, error => %v", len(other.payments), err)
+	}
+}
+
+func TestService_PayBatchContext_rollsBack_user(t *testing.T) {
+	var svc Service
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	items := []types.BatchItem{{AccountID: account.ID, Amount: 10, Category: "Cafe"}}
+	results, err := svc.PayBatchContext(ctx, items, types.BatchBestEffort, 1)
+	if !errors.Is(err, context.Canceled) || !errors.Is(results[0].Err, context.Canceled) {
+		t.Fatalf("cancelled batch returned wrong error => %v", err)
+	}
+	if account.Balance != 100 || len(svc.payments) != 0 {
+		t.Errorf("cancelled batch changed balance => %v", account.Balance)
+	}
+}
+
+func TestService_DeliverWebhooksContext_deadline_user(t *testing.T) {
+	var svc Service
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer server.Close()
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100)
+	svc.RegisterWebhook(server.URL, "secret", account.ID, "Cafe")
+	svc.Pay(account.ID, 10, "Cafe")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	delivered, err := svc.DeliverWebhooksContext(ctx, server.Client())
+	if delivered != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("delivery past deadline => %d, error => %v", delivered, err)
+	}
+	if len(svc.deliveries) != 1 || svc.deliveries[0].Attempts != 1 {
+		t.Errorf("delivery was not kept for retry => %v", svc.deliveries)
+	}
+}
+
+func TestService_PayContext_cancelled_user(t *testing.T) {
+	var svc Service
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	payment, err := svc.PayContext(ctx, account.ID, 10, "Cafe")
+	var cancelErr *CancelError
+	if payment != nil || !errors.Is(err, context.Canceled) || !errors.As(err, &cancelErr) {
+		t.Fatalf("cancelled pay => %v, error => %v", payment, err)
+	}
+	if cancelErr.Op != "pay" {
+		t.Errorf("cancelled pay reported wrong op => %v", cancelErr.Op)
+	}
+	if account.Balance != 100 {
+		t.Errorf("cancelled pay changed balance => %v", account.Balance)
+	}
+}
+
+func TestService_ImportContext_appliesNothing_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 10)
+	dir := t.TempDir()
+	svc.Export(dir)
+
+	other := &Service{}
+	account, _ := other.RegisterAccount("+992000000001")
+	other.Deposit(account.ID, 5)
+	content, _ := ioutil.ReadFile(dir + "/payments.dump")
+	ioutil.WriteFile(dir+"/payments.dump", append(content, "bad\n"...), 0666)
+
+	err := other.ImportContext(context.Background(), dir)
+	if !errors.Is(err, ErrDumpInvalid) {
+		t.Fatalf("method ImportContext returned wrong error, error => %v", err)
+	}
+	if account.Balance != 5 || len(other.accounts) != 1 || len(other.payments) != 0 {
+		t.Errorf("failed import was applied, balance => %v, payments => %d", account.Balance, len(other.payments))
+	}
+
+	ioutil.WriteFile(dir+"/payments.dump", content, 0666)
+	err = other.ImportContext(context.Background(), dir)
+	if err != nil || account.Balance != 0 || len(other.payments) != 10 {
+		t.Errorf("import did not update existing account, balance => %v, error => %v", account.Balance, err)
+	}
+}
@@ -0,0 +1,237 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//Error is wallet error with details, errors.Is matches it with its sentinel in Err
+type Error struct {
+	Err        error
+	AccountID  int64
+	Phone      types.Phone
+	PaymentID  string
+	FavoriteID string
+	Amount     types.Money
+	Balance    types.Money
+}
+
+//Error method
+func (e *Error) Error() string {
+
+	var details []string
+	if e.AccountID != 0 {
+		details = append(details, fmt.Sprintf("account %d", e.AccountID))
+	}
+	if e.Phone != "" {
+		details = append(details, "phone "+string(e.Phone))
+	}
+	if e.PaymentID != "" {
+		details = append(details, "payment "+e.PaymentID)
+	}
+	if e.FavoriteID != "" {
+		details = append(details, "favorite "+e.FavoriteID)
+	}
+	if e.Amount != 0 || e.Err == ErrAmountMustBePositive {
+		details = append(details, fmt.Sprintf("amount %d", e.Amount))
+	}
+	if e.Err == ErrNotEnoughtBalance {
+		details = append(details, fmt.Sprintf("balance %d", e.Balance))
+	}
+	if len(details) == 0 {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + strings.Join(details, ", ")
+}
+
+//Unwrap returns sentinel
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//Code returns stable code of sentinel
+func (e *Error) Code() string {
+	return ErrorCode(e.Err)
+}
+
+//Missing returns how much balance is missing for ErrNotEnoughtBalance
+func (e *Error) Missing() types.Money {
+
+	if e.Err != ErrNotEnoughtBalance {
+		return 0
+	}
+	return e.Amount - e.Balance
+}
+
+//Details returns set fields by their JSON names, balance is set only for ErrNotEnoughtBalance
+func (e *Error) Details() map[string]interface{} {
+
+	details := make(map[string]interface{})
+	if e.AccountID != 0 {
+		details["account_id"] = e.AccountID
+	}
+	if e.Phone != "" {
+		details["phone"] = e.Phone
+	}
+	if e.PaymentID != "" {
+		details["payment_id"] = e.PaymentID
+	}
+	if e.FavoriteID != "" {
+		details["favorite_id"] = e.FavoriteID
+	}
+	if e.Amount != 0 || e.Err == ErrAmountMustBePositive {
+		details["amount"] = e.Amount
+	}
+	if e.Err == ErrNotEnoughtBalance {
+		details["balance"] = e.Balance
+		details["missing"] = e.Missing()
+	}
+	return details
+}
+
+//ErrorDetails returns details of wallet error, nil when err has none
+func ErrorDetails(err error) map[string]interface{} {
+
+	var walletErr *Error
+	if !errors.As(err, &walletErr) {
+		return nil
+	}
+	details := walletErr.Details()
+	if len(details) == 0 {
+		return nil
+	}
+	return details
+}
+
+//errorInfo binds sentinel to stable code, HTTP status and JSON-RPC code, codes must never change or be reused
+type errorInfo struct {
+	err    error
+	code   string
+	status int
+	rpc    int
+}
+
+var errorInfos = []errorInfo{
+	{ErrPhoneRegistered, "phone_registered", http.StatusConflict, 1001},
+	{ErrAmountMustBePositive, "amount_must_be_positive", http.StatusBadRequest, 1002},
+	{ErrAccountNotFound, "account_not_found", http.StatusNotFound, 1003},
+	{ErrNotEnoughtBalance, "not_enough_balance", http.StatusUnprocessableEntity, 1004},
+	{ErrPaymentNotFound, "payment_not_found", http.StatusNotFound, 1005},
+	{ErrFavoriteNotFound, "favorite_not_found", http.StatusNotFound, 1006},
+	{ErrPhoneInvalid, "phone_invalid", http.StatusBadRequest, 1007},
+	{ErrFavoriteNameExists, "favorite_name_exists", http.StatusConflict, 1008},
+	{ErrFavoriteNameInvalid, "favorite_name_invalid", http.StatusBadRequest, 1009},
+	{ErrAccountFrozen, "account_frozen", http.StatusLocked, 1010},
+	{ErrAccountClosed, "account_closed", http.StatusConflict, 1011},
+	{ErrAccountHasBalance, "account_has_balance", http.StatusConflict, 1012},
+	{ErrRewardRuleInvalid, "reward_rule_invalid", http.StatusBadRequest, 1013},
+	{ErrPaymentNotInProgress, "payment_not_in_progress", http.StatusConflict, 1014},
+	{ErrNotEnoughPoints, "not_enough_points", http.StatusUnprocessableEntity, 1015},
+	{ErrScheduleNotFound, "schedule_not_found", http.StatusNotFound, 1016},
+	{ErrScheduleInvalid, "schedule_invalid", http.StatusBadRequest, 1017},
+	{ErrScheduleNotActive, "schedule_not_active", http.StatusConflict, 1018},
+	{ErrBatchAborted, "batch_aborted", http.StatusConflict, 1019},
+	{ErrBatchModeInvalid, "batch_mode_invalid", http.StatusBadRequest, 1020},
+	{ErrMoneyRequestNotFound, "money_request_not_found", http.StatusNotFound, 1021},
+	{ErrMoneyRequestInvalid, "money_request_invalid", http.StatusBadRequest, 1022},
+	{ErrMoneyRequestExpired, "money_request_expired", http.StatusGone, 1023},
+	{ErrMoneyRequestNotPending, "money_request_not_pending", http.StatusConflict, 1024},
+	{ErrCodeNotFound, "code_not_found", http.StatusNotFound, 1025},
+	{ErrCodeInvalid, "code_invalid", http.StatusBadRequest, 1026},
+	{ErrCodeExpired, "code_expired", http.StatusGone, 1027},
+	{ErrCodeAttempts, "code_attempts", http.StatusTooManyRequests, 1028},
+	{ErrVerificationRequired, "verification_required", http.StatusForbidden, 1029},
+	{ErrAccountNotVerified, "account_not_verified", http.StatusForbidden, 1030},
+	{ErrAccountVerified, "account_verified", http.StatusConflict, 1031},
+	{ErrPINInvalid, "pin_invalid", http.StatusBadRequest, 1032},
+	{ErrPINAlreadySet, "pin_already_set", http.StatusConflict, 1033},
+	{ErrPINNotSet, "pin_not_set", http.StatusConflict, 1034},
+	{ErrAuthFailed, "auth_failed", http.StatusUnauthorized, 1035},
+	{ErrAccountLocked, "account_locked", http.StatusLocked, 1036},
+	{ErrSessionNotFound, "session_not_found", http.StatusUnauthorized, 1037},
+	{ErrSessionExpired, "session_expired", http.StatusUnauthorized, 1038},
+	{ErrForbidden, "forbidden", http.StatusForbidden, 1039},
+	{ErrAuditTampered, "audit_tampered", http.StatusInternalServerError, 1040},
+	{ErrWebhookNotFound, "webhook_not_found", http.StatusNotFound, 1041},
+	{ErrWebhookInvalid, "webhook_invalid", http.StatusBadRequest, 1042},
+	{ErrDumpInvalid, "dump_invalid", http.StatusBadRequest, 1043},
+	{ErrPaymentNotRejectable, "payment_not_rejectable", http.StatusConflict, 1044},
+}
+
+func findErrorInfo(err error) *errorInfo {
+
+	for i := range errorInfos {
+		if errors.Is(err, errorInfos[i].err) {
+			return &errorInfos[i]
+		}
+	}
+	return nil
+}
+
+//ErrorCodes returns codes of all wallet errors
+func ErrorCodes() []string {
+
+	var codes []string
+	for _, v := range errorInfos {
+		codes = append(codes, v.code)
+	}
+	return codes
+}
+
+//ErrorCode returns stable code of wallet error, empty for unknown errors
+func ErrorCode(err error) string {
+
+	if info := findErrorInfo(err); info != nil {
+		return info.code
+	}
+	return ""
+}
+
+//ErrorByCode returns sentinel for code, nil for unknown codes
+func ErrorByCode(code string) error {
+
+	for _, v := range errorInfos {
+		if v.code == code {
+			return v.err
+		}
+	}
+	return nil
+}
+
+//HTTPStatus maps wallet error to HTTP status, unknown errors are 500
+func HTTPStatus(err error) int {
+
+	if info := findErrorInfo(err); info != nil {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+//RPCCode maps wallet error to stable JSON-RPC error code, 0 for unknown errors
+func RPCCode(err error) int {
+
+	if info := findErrorInfo(err); info != nil {
+		return info.rpc
+	}
+	return 0
+}
+
+//ErrorByRPCCode returns sentinel for JSON-RPC code, nil for unknown codes
+func ErrorByRPCCode(code int) error {
+
+	for _, v := range errorInfos {
+		if v.rpc == code {
+			return v.err
+		}
+	}
+	return nil
+}
+
+//importError adds file and line to parse error of Import
+func importError(file string, line int, err error) error {
+	return fmt.Errorf("import %s line %d: %w", file, line, err)
+}
@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestError_notEnoughBalance_user(t *testing.T) {
+	var svc Service
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 500)
+
+	_, err := svc.Pay(account.ID, 1_000, "Cafe")
+	if !errors.Is(err, ErrNotEnoughtBalance) {
+		t.Fatalf("method Pay returned wrong error, error => %v", err)
+	}
+	var walletErr *Error
+	if !errors.As(err, &walletErr) {
+		t.Fatalf("method Pay returned not structured error => %v", err)
+	}
+	if walletErr.AccountID != account.ID || walletErr.Amount != 1_000 || walletErr.Balance != 500 || walletErr.Missing() != 500 {
+		t.Errorf("wrong details => %+v", walletErr)
+	}
+	if walletErr.Code() != "not_enough_balance" || HTTPStatus(err) != http.StatusUnprocessableEntity || RPCCode(err) != 1004 {
+		t.Errorf("wrong mapping => %v %v %v", walletErr.Code(), HTTPStatus(err), RPCCode(err))
+	}
+	if err.Error() != "account not enought balance: account 1, amount 1000, balance 500" {
+		t.Errorf("wrong message => %v", err)
+	}
+	details := ErrorDetails(err)
+	if details["account_id"] != account.ID || details["balance"] != walletErr.Balance {
+		t.Errorf("wrong details map => %v", details)
+	}
+}
+
+func TestError_notFound_user(t *testing.T) {
+	var svc Service
+
+	_, err := svc.FindAccountByID(7)
+	var walletErr *Error
+	if !errors.As(err, &walletErr) || walletErr.Err != ErrAccountNotFound || walletErr.AccountID != 7 {
+		t.Errorf("wrong account error => %v", err)
+	}
+	_, err = svc.FindPaymentByID("x")
+	if !errors.As(err, &walletErr) || walletErr.Err != ErrPaymentNotFound || walletErr.PaymentID != "x" {
+		t.Errorf("wrong payment error => %v", err)
+	}
+	if ErrorDetails(ErrBatchAborted) != nil || ErrorDetails(errors.New("other")) != nil {
+		t.Errorf("details for plain errors")
+	}
+	if HTTPStatus(errors.New("other")) != http.StatusInternalServerError || ErrorCode(errors.New("other")) != "" {
+		t.Errorf("wrong mapping for unknown error")
+	}
+}
+
+func TestImport_parseErrorContext_user(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := "1;+992000000001;100;ACTIVE\n2;+992000000002;abc;ACTIVE\n"
+	ioutil.WriteFile(filepath.Join(dir, "accounts.dump"), []byte(content), 0666)
+	var svc Service
+	err = svc.Import(dir)
+	if err == nil || !strings.Contains(err.Error(), "accounts.dump line 2") {
+		t.Errorf("method Import returned error without context => %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "accounts.dump"), []byte("1;+992000000001\n"), 0666)
+	err = svc.Import(dir)
+	if !errors.Is(err, ErrDumpInvalid) || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("method Import returned wrong error => %v", err)
+	}
+}
+
+func TestErrorInfos_coverSentinels_user(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declared := 0
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if strings.HasPrefix(name.Name, "Err") {
+						declared++
+					}
+				}
+			}
+		}
+	}
+	if declared != len(errorInfos) {
+		t.Errorf("declared %v sentinels, mapped %v", declared, len(errorInfos))
+	}
+
+	errs := make(map[error]bool)
+	codes := make(map[string]bool)
+	rpcCodes := make(map[int]bool)
+	for _, v := range errorInfos {
+		if errs[v.err] || codes[v.code] || rpcCodes[v.rpc] {
+			t.Errorf("duplicate mapping => %v", v)
+		}
+		errs[v.err] = true
+		codes[v.code] = true
+		rpcCodes[v.rpc] = true
+		if ErrorByCode(v.code) != v.err || ErrorByRPCCode(v.rpc) != v.err {
+			t.Errorf("mapping not reversible => %v", v)
+		}
+	}
+}
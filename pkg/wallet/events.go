@@ -0,0 +1,193 @@
+package wallet
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//EventHandler receives domain events
+type EventHandler func(event types.Event)
+
+//Delivery says how events are given to handler
+type Delivery int
+
+//Delivery modes
+const (
+	//DeliverSync calls handler inside mutating method
+	DeliverSync Delivery = iota
+	//DeliverAsync calls handler from background goroutines, events of one account keep order.
+	//Queues have no limit, so mutating method never waits for handler and handler may hold Locker.
+	DeliverAsync
+)
+
+const asyncShards = 4
+
+type subscription struct {
+	handler EventHandler
+	shards  []*asyncShard
+	wg      sync.WaitGroup
+}
+
+//asyncShard is unbounded queue of events given to handler by one goroutine
+type asyncShard struct {
+	mu     sync.Mutex
+	events []types.Event
+	closed bool
+	ready  chan struct{}
+}
+
+func newAsyncShard() *asyncShard {
+	return &asyncShard{ready: make(chan struct{}, 1)}
+}
+
+func (q *asyncShard) push(event types.Event) {
+
+	q.mu.Lock()
+	q.events = append(q.events, event)
+	q.mu.Unlock()
+	q.wake()
+}
+
+//close lets run return after queued events are delivered
+func (q *asyncShard) close() {
+
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *asyncShard) wake() {
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *asyncShard) run(handler EventHandler) {
+
+	for {
+		q.mu.Lock()
+		events, closed := q.events, q.closed
+		q.events = nil
+		q.mu.Unlock()
+
+		for _, event := range events {
+			handler(event)
+		}
+		if len(events) == 0 {
+			if closed {
+				return
+			}
+			<-q.ready
+		}
+	}
+}
+
+type eventBus struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]*subscription
+}
+
+//Subscribe registers handler and returns id for Unsubscribe.
+//Sync handler is called without lock of bus, so it may Subscribe or Unsubscribe, change applies to next event.
+func (s *Service) Subscribe(handler EventHandler, delivery Delivery) int {
+
+	if s.bus == nil {
+		s.bus = &eventBus{subs: make(map[int]*subscription)}
+	}
+	sub := &subscription{handler: handler}
+	if delivery == DeliverAsync {
+		for i := 0; i < asyncShards; i++ {
+			shard := newAsyncShard()
+			sub.shards = append(sub.shards, shard)
+			sub.wg.Add(1)
+			go func() {
+				defer sub.wg.Done()
+				shard.run(handler)
+			}()
+		}
+	}
+
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.nextID++
+	s.bus.subs[s.bus.nextID] = sub
+	return s.bus.nextID
+}
+
+//Unsubscribe removes handler, for async handler it waits until queued events are delivered
+func (s *Service) Unsubscribe(id int) {
+
+	if s.bus == nil {
+		return
+	}
+	s.bus.mu.Lock()
+	sub, ok := s.bus.subs[id]
+	delete(s.bus.subs, id)
+	if ok {
+		for _, shard := range sub.shards {
+			shard.close()
+		}
+	}
+	s.bus.mu.Unlock()
+
+	if ok {
+		sub.wg.Wait()
+	}
+}
+
+func (s *Service) publish(event types.Event) {
+
+	s.eventSeq++
+	event.Seq = s.eventSeq
+	event.ID = uuid.New().String()
+	event.At = s.currentTime()
+	if event.AccountID != 0 {
+		if account, err := s.FindAccountByID(event.AccountID); err == nil {
+			copied := *account
+			event.Account = &copied
+		}
+	}
+	if event.Payment != nil {
+		copied := *event.Payment
+		event.Payment = &copied
+	}
+	if event.Favorite != nil {
+		copied := *event.Favorite
+		event.Favorite = &copied
+	}
+	s.enqueueOutbox(event)
+	s.enqueueWebhooks(event)
+
+	if s.bus == nil {
+		return
+	}
+	// async events are queued under lock, so Unsubscribe does not close queue in between
+	var handlers []EventHandler
+	s.bus.mu.Lock()
+	for id := 1; id <= s.bus.nextID; id++ {
+		sub, ok := s.bus.subs[id]
+		if !ok {
+			continue
+		}
+		if sub.shards == nil {
+			handlers = append(handlers, sub.handler)
+			continue
+		}
+		shard := event.AccountID % int64(len(sub.shards))
+		if shard < 0 {
+			shard = -shard
+		}
+		sub.shards[shard].push(event)
+	}
+	s.bus.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
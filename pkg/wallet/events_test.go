@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestService_Subscribe_sync_user(t *testing.T) {
+	var svc Service
+
+	var events []types.Event
+	id := svc.Subscribe(func(event types.Event) {
+		events = append(events, event)
+	}, DeliverSync)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	svc.FavoritePayment(payment.ID, "Lunch")
+	svc.Reject(payment.ID)
+
+	want := []types.EventType{
+		types.EventAccountRegistered,
+		types.EventDeposited,
+		types.EventPaymentCreated,
+		types.EventFavoriteCreated,
+		types.EventPaymentRejected,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("wrong events count => %v", len(events))
+	}
+	for i, v := range events {
+		if v.Type != want[i] || v.Seq != int64(i+1) || v.AccountID != account.ID {
+			t.Errorf("wrong event %d => %v", i, v)
+		}
+	}
+	if events[2].Payment.Status != types.PaymentStatusInProgress || events[2].Account.Balance != 90_00 {
+		t.Errorf("event is not snapshot => %v %v", events[2].Payment, events[2].Account)
+	}
+
+	svc.Unsubscribe(id)
+	svc.Deposit(account.ID, 1)
+	if len(events) != len(want) {
+		t.Errorf("event delivered after unsubscribe")
+	}
+}
+
+func TestService_Subscribe_async_user(t *testing.T) {
+	var svc Service
+
+	mu := sync.Mutex{}
+	got := make(map[int64][]int64)
+	id := svc.Subscribe(func(event types.Event) {
+		mu.Lock()
+		got[event.AccountID] = append(got[event.AccountID], event.Seq)
+		mu.Unlock()
+	}, DeliverAsync)
+
+	for i := 0; i < 5; i++ {
+		account, _ := svc.RegisterAccount(types.Phone("+99200000000" + string(rune('1'+i))))
+		for j := 0; j < 50; j++ {
+			svc.Deposit(account.ID, 1)
+		}
+	}
+	svc.Unsubscribe(id)
+
+	if len(got) != 5 {
+		t.Fatalf("wrong accounts => %v", len(got))
+	}
+	for accountID, seqs := range got {
+		if len(seqs) != 51 {
+			t.Errorf("account %d wrong events count => %v", accountID, len(seqs))
+		}
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] <= seqs[i-1] {
+				t.Errorf("account %d events out of order => %v", accountID, seqs)
+				break
+			}
+		}
+	}
+}
+
+func TestService_Subscribe_handlerUnsubscribes_user(t *testing.T) {
+	var svc Service
+
+	calls := 0
+	var id int
+	id = svc.Subscribe(func(event types.Event) {
+		calls++
+		svc.Unsubscribe(id)
+	}, DeliverSync)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	if calls != 1 {
+		t.Errorf("handler called after Unsubscribe => %d", calls)
+	}
+}
+
+func TestService_Subscribe_asyncHandlerHoldsLocker_user(t *testing.T) {
+	var svc Service
+	account, _ := svc.RegisterAccount("+992000000001")
+
+	balances := make(chan types.Money, 1000)
+	id := svc.Subscribe(func(event types.Event) {
+		svc.Locker().Lock()
+		defer svc.Locker().Unlock()
+		found, _ := svc.FindAccountByID(event.AccountID)
+		balances <- found.Balance
+	}, DeliverAsync)
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		svc.Locker().Lock()
+		defer svc.Locker().Unlock()
+		for i := 0; i < 500; i++ {
+			svc.Deposit(account.ID, 1)
+		}
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish waited for handler which waits for Locker")
+	}
+	svc.Unsubscribe(id)
+	if len(balances) != 500 {
+		t.Errorf("handler got %d events", len(balances))
+	}
+}
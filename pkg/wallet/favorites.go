@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrFavoriteNameExists -- favorite name already used by account
+var ErrFavoriteNameExists = errors.New("favorite name already exists")
+
+//ErrFavoriteNameInvalid -- favorite name is empty or has forbidden symbols
+var ErrFavoriteNameInvalid = errors.New("favorite name is invalid")
+
+//AccountFavorites returns favorites of account
+func (s *Service) AccountFavorites(accountID int64) ([]types.Favorite, error) {
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var favorites []types.Favorite
+	for _, v := range s.favorites {
+		if v.AccountID == accountID {
+			favorites = append(favorites, *v)
+		}
+	}
+	return favorites, nil
+}
+
+//FindFavoriteByID returns favorite only if it belongs to account
+func (s *Service) FindFavoriteByID(accountID int64, favoriteID string) (*types.Favorite, error) {
+
+	favorite, err := s.findFavorite(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	if favorite.AccountID != accountID {
+		return nil, &Error{Err: ErrFavoriteNotFound, AccountID: accountID, FavoriteID: favoriteID}
+	}
+	return favorite, nil
+}
+
+//UpdateFavorite changes name and amount of account favorite
+func (s *Service) UpdateFavorite(accountID int64, favoriteID string, name string, amount types.Money) (*types.Favorite, error) {
+
+	if amount <= 0 {
+		return nil, &Error{Err: ErrAmountMustBePositive, AccountID: accountID, FavoriteID: favoriteID, Amount: amount}
+	}
+	favorite, err := s.FindFavoriteByID(accountID, favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkFavoriteName(accountID, favoriteID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	favorite.Name = name
+	favorite.Amount = amount
+	s.publish(types.Event{Type: types.EventFavoriteUpdated, AccountID: accountID, Favorite: favorite})
+	return favorite, nil
+}
+
+//DeleteFavorite removes account favorite and finishes its schedules
+func (s *Service) DeleteFavorite(accountID int64, favoriteID string) error {
+
+	favorite, err := s.FindFavoriteByID(accountID, favoriteID)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range s.favorites {
+		if v.ID == favoriteID {
+			s.favorites = append(s.favorites[:i], s.favorites[i+1:]...)
+			break
+		}
+	}
+	for _, v := range s.schedules {
+		if v.FavoriteID == favoriteID {
+			v.Status = types.ScheduleDone
+		}
+	}
+	s.publish(types.Event{Type: types.EventFavoriteDeleted, AccountID: accountID, Favorite: favorite})
+	return nil
+}
+
+func (s *Service) findFavorite(favoriteID string) (*types.Favorite, error) {
+
+	for _, v := range s.favorites {
+		if v.ID == favoriteID {
+			return v, nil
+		}
+	}
+	return nil, &Error{Err: ErrFavoriteNotFound, FavoriteID: favoriteID}
+}
+
+func (s *Service) checkFavoriteName(accountID int64, favoriteID string, name string) error {
+
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, ";\n") {
+		return ErrFavoriteNameInvalid
+	}
+	for _, v := range s.favorites {
+		if v.AccountID == accountID && v.ID != favoriteID && v.Name == name {
+			return ErrFavoriteNameExists
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestService_UpdateFavorite_success_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	favorite, _ := svc.FavoritePayment(payment.ID, "Lunch")
+	other, _ := svc.FavoritePayment(payment.ID, "Dinner")
+
+	_, err := svc.UpdateFavorite(account.ID, favorite.ID, "Dinner", 20_00)
+	if !errors.Is(err, ErrFavoriteNameExists) {
+		t.Errorf("method UpdateFavorite returned wrong error, error => %v", err)
+	}
+
+	updated, err := svc.UpdateFavorite(account.ID, favorite.ID, "Breakfast", 20_00)
+	if err != nil {
+		t.Fatalf("method UpdateFavorite returned not nil error, error => %v", err)
+	}
+	if updated.Name != "Breakfast" || updated.Amount != 20_00 {
+		t.Errorf("favorite not updated => %v", updated)
+	}
+
+	err = svc.DeleteFavorite(account.ID, other.ID)
+	if err != nil {
+		t.Errorf("method DeleteFavorite returned not nil error, error => %v", err)
+	}
+
+	favorites, err := svc.AccountFavorites(account.ID)
+	if err != nil || len(favorites) != 1 || favorites[0].ID != favorite.ID {
+		t.Errorf("wrong favorites => %v, error => %v", favorites, err)
+	}
+}
+
+func TestService_FindFavoriteByID_notOwner_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	stranger, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	favorite, _ := svc.FavoritePayment(payment.ID, "Lunch")
+
+	_, err := svc.FindFavoriteByID(stranger.ID, favorite.ID)
+	if !errors.Is(err, ErrFavoriteNotFound) {
+		t.Errorf("method FindFavoriteByID returned wrong error, error => %v", err)
+	}
+
+	err = svc.DeleteFavorite(stranger.ID, favorite.ID)
+	if !errors.Is(err, ErrFavoriteNotFound) {
+		t.Errorf("method DeleteFavorite returned wrong error, error => %v", err)
+	}
+}
+
+func TestService_ExportImport_favoriteName_user(t *testing.T) {
+	var svc Service
+
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	favorite, _ := svc.FavoritePayment(payment.ID, "Lunch")
+
+	svc.Export(dir)
+
+	var imported Service
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("method Import returned not nil error, error => %v", err)
+	}
+
+	got, err := imported.FindFavoriteByID(account.ID, favorite.ID)
+	if err != nil {
+		t.Fatalf("method FindFavoriteByID returned not nil error, error => %v", err)
+	}
+	if *got != *favorite {
+		t.Errorf("favorite not persisted, want => %v got => %v", favorite, got)
+	}
+}
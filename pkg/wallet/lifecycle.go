@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrAccountFrozen -- account is frozen
+var ErrAccountFrozen = errors.New("account is frozen")
+
+//ErrAccountClosed -- account is closed
+var ErrAccountClosed = errors.New("account is closed")
+
+//ErrAccountHasBalance -- account can not be closed with balance
+var ErrAccountHasBalance = errors.New("account has balance")
+
+//FreezeAccount blocks all payments from account
+func (s *Service) FreezeAccount(accountID int64) error {
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return err
+	}
+
+	before := *account
+	account.Status = types.AccountFrozen
+	s.audit("account.freeze", before, account)
+	s.publish(types.Event{Type: types.EventAccountStatusChanged, AccountID: accountID})
+	return nil
+}
+
+//UnfreezeAccount method
+func (s *Service) UnfreezeAccount(accountID int64) error {
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return err
+	}
+
+	before := *account
+	account.Status = types.AccountActive
+	s.audit("account.unfreeze", before, account)
+	s.publish(types.Event{Type: types.EventAccountStatusChanged, AccountID: accountID})
+	return nil
+}
+
+//CloseAccount closes account, balance is moved to sweepToID or must be zero when sweepToID is 0.
+//Frozen account must be unfrozen before it is closed.
+func (s *Service) CloseAccount(accountID int64, sweepToID int64) error {
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return err
+	}
+	before := *account
+
+	if account.Balance != 0 {
+		if sweepToID == 0 {
+			return ErrAccountHasBalance
+		}
+		if sweepToID == accountID {
+			return &Error{Err: ErrAccountClosed, AccountID: accountID}
+		}
+		target, err := s.FindAccountByID(sweepToID)
+		if err != nil {
+			return err
+		}
+		err = checkOpen(target)
+		if err != nil {
+			return err
+		}
+		targetBefore := *target
+		amount := account.Balance
+		target.Balance += amount
+		account.Balance = 0
+		s.audit("account.sweep", targetBefore, target)
+		s.publish(types.Event{Type: types.EventDeposited, AccountID: target.ID, Amount: amount})
+	}
+
+	account.Status = types.AccountClosed
+	s.audit("account.close", before, account)
+	s.publish(types.Event{Type: types.EventAccountStatusChanged, AccountID: accountID})
+	return nil
+}
+
+//validStatus tells if status is known, empty status is not valid
+func validStatus(status types.AccountStatus) bool {
+
+	switch status {
+	case types.AccountActive, types.AccountFrozen, types.AccountClosed:
+		return true
+	}
+	return false
+}
+
+//checkActive allows money to leave account
+func checkActive(account *types.Account) error {
+
+	switch account.Status {
+	case types.AccountFrozen:
+		return &Error{Err: ErrAccountFrozen, AccountID: account.ID}
+	case types.AccountClosed:
+		return &Error{Err: ErrAccountClosed, AccountID: account.ID}
+	}
+	return nil
+}
+
+//checkOpen allows money to come to account
+func checkOpen(account *types.Account) error {
+
+	if account.Status == types.AccountClosed {
+		return &Error{Err: ErrAccountClosed, AccountID: account.ID}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestService_FreezeAccount_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	favorite, _ := svc.FavoritePayment(payment.ID, "Lunch")
+
+	err := svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Fatalf("method FreezeAccount returned not nil error, error => %v", err)
+	}
+
+	_, err = svc.Pay(account.ID, 10_00, "Cafe")
+	if !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("method Pay returned wrong error, error => %v", err)
+	}
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("method PayFromFavorite returned wrong error, error => %v", err)
+	}
+
+	err = svc.UnfreezeAccount(account.ID)
+	if err != nil {
+		t.Fatalf("method UnfreezeAccount returned not nil error, error => %v", err)
+	}
+	_, err = svc.Pay(account.ID, 10_00, "Cafe")
+	if err != nil {
+		t.Errorf("method Pay returned not nil error, error => %v", err)
+	}
+}
+
+func TestService_CloseAccount_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	target, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(account.ID, 100_00)
+
+	err := svc.CloseAccount(account.ID, 0)
+	if !errors.Is(err, ErrAccountHasBalance) {
+		t.Errorf("method CloseAccount returned wrong error, error => %v", err)
+	}
+
+	err = svc.CloseAccount(account.ID, target.ID)
+	if err != nil {
+		t.Fatalf("method CloseAccount returned not nil error, error => %v", err)
+	}
+	if account.Balance != 0 || target.Balance != 100_00 {
+		t.Errorf("balance not swept => %v %v", account.Balance, target.Balance)
+	}
+
+	err = svc.Deposit(account.ID, 10_00)
+	if !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("method Deposit returned wrong error, error => %v", err)
+	}
+	err = svc.UnfreezeAccount(account.ID)
+	if !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("method UnfreezeAccount returned wrong error, error => %v", err)
+	}
+}
+
+func TestService_ExportImport_accountStatus_user(t *testing.T) {
+	var svc Service
+
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.FreezeAccount(account.ID)
+	svc.Export(dir)
+
+	var imported Service
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("method Import returned not nil error, error => %v", err)
+	}
+	got, _ := imported.FindAccountByID(account.ID)
+	if got.Status != types.AccountFrozen {
+		t.Errorf("status not persisted => %v", got.Status)
+	}
+}
+
+func TestService_CloseAccount_frozen_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	target, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(account.ID, 100_00)
+	svc.FreezeAccount(account.ID)
+
+	err := svc.CloseAccount(account.ID, target.ID)
+	if !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("method CloseAccount returned wrong error, error => %v", err)
+	}
+	if account.Balance != 100_00 || target.Balance != 0 || account.Status != types.AccountFrozen {
+		t.Errorf("frozen account was swept => %v %v", account.Balance, target.Balance)
+	}
+}
+
+func TestService_lifecycle_audited_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	target, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(account.ID, 100_00)
+	svc.FreezeAccount(account.ID)
+	svc.UnfreezeAccount(account.ID)
+	svc.CloseAccount(account.ID, target.ID)
+
+	var actions []string
+	for _, v := range svc.AuditLog()[3:] {
+		actions = append(actions, v.Action)
+	}
+	want := "[account.freeze account.unfreeze account.sweep account.close]"
+	if got := fmt.Sprint(actions); got != want {
+		t.Errorf("wrong audit actions => %v", got)
+	}
+}
+
+func TestService_Import_unknownStatus_user(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(dir+"/accounts.dump", []byte("1;+992000000001;0;BLOCKED\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var svc Service
+	err = svc.Import(dir)
+	if !errors.Is(err, ErrDumpInvalid) {
+		t.Errorf("method Import returned wrong error, error => %v", err)
+	}
+}
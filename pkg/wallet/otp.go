@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrCodeNotFound -- no code was sent or it was already used
+var ErrCodeNotFound = errors.New("verification code not found")
+
+//ErrCodeInvalid -- verification code is wrong
+var ErrCodeInvalid = errors.New("verification code is invalid")
+
+//ErrCodeExpired -- verification code expired
+var ErrCodeExpired = errors.New("verification code expired")
+
+//ErrCodeAttempts -- too many wrong verification codes
+var ErrCodeAttempts = errors.New("too many verification attempts")
+
+const (
+	defaultCodeTTL      = 5 * time.Minute
+	defaultCodeAttempts = 3
+)
+
+//CodeSender delivers one-time codes to phone
+type CodeSender interface {
+	Send(phone types.Phone, code string) error
+}
+
+//SetCodeSender sets sender of one-time codes, after that new accounts must be verified
+func (s *Service) SetCodeSender(sender CodeSender) {
+	s.codeSender = sender
+}
+
+//SetCodePolicy sets how long code lives and how many wrong attempts are allowed
+func (s *Service) SetCodePolicy(ttl time.Duration, attempts int) {
+	s.codeTTL = ttl
+	s.codeAttempts = attempts
+}
+
+//MemoryCodeSender keeps sent codes in memory, used in tests
+type MemoryCodeSender struct {
+	mu    sync.Mutex
+	codes map[types.Phone]string
+}
+
+//Send method
+func (m *MemoryCodeSender) Send(phone types.Phone, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.codes == nil {
+		m.codes = make(map[types.Phone]string)
+	}
+	m.codes[phone] = code
+	return nil
+}
+
+//LastCode returns last code sent to phone
+func (m *MemoryCodeSender) LastCode(phone types.Phone) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.codes[phone]
+}
+
+type pendingCode struct {
+	code      string
+	phone     types.Phone
+	expiresAt time.Time
+}
+
+//codeFailures counts wrong codes of key, it outlives codes, so resend does not give new attempts
+type codeFailures struct {
+	count   int
+	resetAt time.Time
+}
+
+//issueCode sends new code for key, key which used all attempts gets no code until its failures are reset
+func (s *Service) issueCode(key string, phone types.Phone) error {
+
+	if failures := s.failuresOf(key); failures != nil && failures.count >= s.getCodeAttempts() {
+		return ErrCodeAttempts
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	err = s.codeSender.Send(phone, code)
+	if err != nil {
+		return err
+	}
+
+	if s.codes == nil {
+		s.codes = make(map[string]*pendingCode)
+	}
+	s.codes[key] = &pendingCode{
+		code:      code,
+		phone:     phone,
+		expiresAt: s.currentTime().Add(s.getCodeTTL()),
+	}
+	return nil
+}
+
+//verifyCode checks code and forgets it when it is right, expired or attempts are over
+func (s *Service) verifyCode(key string, code string) (*pendingCode, error) {
+
+	pending, ok := s.codes[key]
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+	if !s.currentTime().Before(pending.expiresAt) {
+		delete(s.codes, key)
+		return nil, ErrCodeExpired
+	}
+	if pending.code != code {
+		failures := s.failuresOf(key)
+		if failures == nil {
+			if s.codeFailures == nil {
+				s.codeFailures = make(map[string]*codeFailures)
+			}
+			failures = &codeFailures{resetAt: s.currentTime().Add(s.getCodeTTL())}
+			s.codeFailures[key] = failures
+		}
+		failures.count++
+		if failures.count >= s.getCodeAttempts() {
+			delete(s.codes, key)
+			return nil, ErrCodeAttempts
+		}
+		return nil, ErrCodeInvalid
+	}
+
+	delete(s.codes, key)
+	delete(s.codeFailures, key)
+	return pending, nil
+}
+
+//failuresOf returns failures of key, failures are forgotten after code TTL since first of them
+func (s *Service) failuresOf(key string) *codeFailures {
+
+	failures, ok := s.codeFailures[key]
+	if !ok {
+		return nil
+	}
+	if !s.currentTime().Before(failures.resetAt) {
+		delete(s.codeFailures, key)
+		return nil
+	}
+	return failures
+}
+
+func (s *Service) getCodeTTL() time.Duration {
+	if s.codeTTL <= 0 {
+		return defaultCodeTTL
+	}
+	return s.codeTTL
+}
+
+func (s *Service) getCodeAttempts() int {
+	if s.codeAttempts <= 0 {
+		return defaultCodeAttempts
+	}
+	return s.codeAttempts
+}
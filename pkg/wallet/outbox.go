@@ -0,0 +1,375 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+const (
+	relayBackoff    = time.Second
+	relayMaxBackoff = 5 * time.Minute
+)
+
+//DefaultOutboxLimit is number of pending events kept in memory when limit is not set
+const DefaultOutboxLimit = 10_000
+
+//EventSink receives events from outbox relay, event may come more than once
+type EventSink interface {
+	Deliver(event types.Event) error
+}
+
+//SetOutboxLimit sets how many pending events outbox keeps in memory, 0 or less means DefaultOutboxLimit.
+//Events over limit are written to spill file set by SetOutboxSpill, without it they stay in memory, so no event is lost.
+func (s *Service) SetOutboxLimit(limit int) {
+	s.outboxLimit = limit
+}
+
+//SetOutboxSpill sets file which keeps events over limit until relay makes room for them
+func (s *Service) SetOutboxSpill(path string) {
+	s.outboxSpill = path
+}
+
+//OutboxSpilled returns number of events waiting in spill file
+func (s *Service) OutboxSpilled() int {
+	return s.outboxSpilled
+}
+
+func (s *Service) getOutboxLimit() int {
+	if s.outboxLimit <= 0 {
+		return DefaultOutboxLimit
+	}
+	return s.outboxLimit
+}
+
+func (s *Service) enqueueOutbox(event types.Event) {
+
+	entry := &types.OutboxEntry{Event: event, NextAttempt: event.At}
+	if len(s.outbox) >= s.getOutboxLimit() {
+		s.PruneOutbox()
+	}
+	// spilled events are older, so new event goes after them while any is in file
+	full := s.outboxSpilled > 0 || len(s.outbox) >= s.getOutboxLimit()
+	if full && s.outboxSpill != "" && s.spillOutbox(entry) == nil {
+		return
+	}
+	s.outbox = append(s.outbox, entry)
+}
+
+func (s *Service) spillOutbox(entry *types.OutboxEntry) error {
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.outboxSpill, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	s.outboxSpilled++
+	return nil
+}
+
+//readSpill returns events of spill file in order
+func (s *Service) readSpill() ([]*types.OutboxEntry, error) {
+
+	if s.outboxSpilled == 0 {
+		return nil, nil
+	}
+	lines, err := readDump(filepath.Dir(s.outboxSpill), filepath.Base(s.outboxSpill))
+	if err != nil {
+		return nil, err
+	}
+	var entries []*types.OutboxEntry
+	for i, line := range lines {
+		entry := &types.OutboxEntry{}
+		err := json.Unmarshal([]byte(line), entry)
+		if err != nil {
+			return nil, importError(s.outboxSpill, i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+//refillOutbox moves spilled events back to memory while outbox has room, file keeps the rest
+func (s *Service) refillOutbox() error {
+
+	entries, err := s.readSpill()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	room := s.getOutboxLimit() - len(s.outbox)
+	if room <= 0 {
+		return nil
+	}
+	if room > len(entries) {
+		room = len(entries)
+	}
+
+	var rest strings.Builder
+	for _, v := range entries[room:] {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		rest.WriteString(string(data) + "\n")
+	}
+	if rest.Len() == 0 {
+		err = os.Remove(s.outboxSpill)
+	} else {
+		err = ioutil.WriteFile(s.outboxSpill, []byte(rest.String()), 0666)
+	}
+	if err != nil {
+		return err
+	}
+	s.outbox = append(s.outbox, entries[:room]...)
+	s.outboxSpilled = len(entries) - room
+	return nil
+}
+
+//PendingOutbox returns events which are not delivered yet, events of spill file are not included
+func (s *Service) PendingOutbox() []types.OutboxEntry {
+
+	var entries []types.OutboxEntry
+	for _, v := range s.outbox {
+		if !v.Delivered {
+			entries = append(entries, *v)
+		}
+	}
+	return entries
+}
+
+//PruneOutbox removes delivered events
+func (s *Service) PruneOutbox() {
+
+	var pending []*types.OutboxEntry
+	for _, v := range s.outbox {
+		if !v.Delivered {
+			pending = append(pending, v)
+		}
+	}
+	s.outbox = pending
+}
+
+//RelayOutbox delivers due events to sink in order and returns count of delivered.
+//When event of account fails later events of that account wait for next pass.
+//Delivered events are pruned after every pass and spilled events are moved to their place.
+func (s *Service) RelayOutbox(sink EventSink) int {
+
+	delivered, _ := s.relayOutbox(newTracker(context.Background(), "relay outbox", 0), sink, noLocker{})
+	return delivered
+}
+
+//outboxDelivery is due event of outbox, err is result of its delivery
+type outboxDelivery struct {
+	entry     *types.OutboxEntry
+	event     types.Event
+	attempted bool
+	err       error
+}
+
+//relayOutbox unlocks outside while sink is called, so slow sink does not hold service
+func (s *Service) relayOutbox(t *tracker, sink EventSink, outside sync.Locker) (int, error) {
+
+	now := s.currentTime()
+	var due []*outboxDelivery
+	waiting := make(map[int64]bool)
+	for _, v := range s.outbox {
+		if v.Delivered {
+			continue
+		}
+		if waiting[v.Event.AccountID] || v.NextAttempt.After(now) {
+			waiting[v.Event.AccountID] = true
+			continue
+		}
+		due = append(due, &outboxDelivery{entry: v, event: v.Event})
+	}
+	t.total = len(due)
+
+	outside.Unlock()
+	failed := make(map[int64]bool)
+	var err error
+	for _, v := range due {
+		if err = t.check(); err != nil {
+			break
+		}
+		t.add(1)
+		if failed[v.event.AccountID] {
+			continue
+		}
+		v.attempted = true
+		v.err = sink.Deliver(v.event)
+		if v.err != nil {
+			failed[v.event.AccountID] = true
+		}
+	}
+	outside.Lock()
+
+	delivered := 0
+	for _, v := range due {
+		if !v.attempted {
+			continue
+		}
+		v.entry.Attempts++
+		if v.err != nil {
+			v.entry.LastError = v.err.Error()
+			v.entry.NextAttempt = now.Add(backoff(v.entry.Attempts))
+			continue
+		}
+		v.entry.Delivered = true
+		v.entry.LastError = ""
+		delivered++
+	}
+	s.PruneOutbox()
+	s.refillOutbox()
+	return delivered, err
+}
+
+//noLocker is Locker of caller which already holds service or uses it from one goroutine
+type noLocker struct{}
+
+func (noLocker) Lock()   {}
+func (noLocker) Unlock() {}
+
+//StartRelay runs RelayOutbox on every tick until returned stop is called.
+//Every tick holds Locker, but sink is called without it.
+func (s *Service) StartRelay(sink EventSink, interval time.Duration) (stop func()) {
+	return s.StartRelayContext(context.Background(), sink, interval)
+}
+
+//StartRelayContext is StartRelay which also stops when ctx is done, ctx is passed to every relay
+func (s *Service) StartRelayContext(ctx context.Context, sink EventSink, interval time.Duration) (stop func()) {
+
+	return s.startLoop(ctx, interval, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.relayOutbox(newTracker(ctx, "relay outbox", 0), sink, &s.lock)
+	})
+}
+
+//DefaultDedupSize is number of event IDs remembered by DedupSink when Size is not set
+const DefaultDedupSize = 100_000
+
+//DedupSink drops events with already seen ID before passing them to Sink.
+//It remembers last Size IDs, so duplicate of older event is passed again.
+type DedupSink struct {
+	Sink EventSink
+	Size int
+
+	mu    sync.Mutex
+	seen  map[string]bool
+	order []string
+}
+
+//Deliver method
+func (d *DedupSink) Deliver(event types.Event) error {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen[event.ID] {
+		return nil
+	}
+	err := d.Sink.Deliver(event)
+	if err != nil {
+		return err
+	}
+	if d.seen == nil {
+		d.seen = make(map[string]bool)
+	}
+	size := d.Size
+	if size <= 0 {
+		size = DefaultDedupSize
+	}
+	for len(d.order) >= size {
+		delete(d.seen, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.seen[event.ID] = true
+	d.order = append(d.order, event.ID)
+	return nil
+}
+
+func backoff(attempts int) time.Duration {
+
+	delay := relayBackoff
+	for i := 1; i < attempts && delay < relayMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > relayMaxBackoff {
+		delay = relayMaxBackoff
+	}
+	return delay
+}
+
+//exportOutbox writes pending events of memory and spill file, so import gets all of them
+func (s *Service) exportOutbox(t *tracker) (string, error) {
+
+	spilled, err := s.readSpill()
+	if err != nil {
+		return "", err
+	}
+	var str strings.Builder
+	for _, v := range append(append([]*types.OutboxEntry(nil), s.outbox...), spilled...) {
+		if err := t.check(); err != nil {
+			return "", err
+		}
+		t.add(1)
+		if v.Delivered {
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		str.WriteString(string(data) + "\n")
+	}
+	return str.String(), nil
+}
+
+func (s *Service) importOutbox(t *tracker, lines []string) error {
+
+	for i, line := range lines {
+		if err := t.check(); err != nil {
+			return err
+		}
+		t.add(1)
+		if line == "" {
+			continue
+		}
+		entry := &types.OutboxEntry{}
+		err := json.Unmarshal([]byte(line), entry)
+		if err != nil {
+			return importError("outbox.dump", i+1, err)
+		}
+
+		flag := true
+		for i, v := range s.outbox {
+			if v.Event.ID == entry.Event.ID {
+				s.outbox[i] = entry
+				flag = false
+			}
+		}
+		if flag {
+			s.outbox = append(s.outbox, entry)
+		}
+		if entry.Event.Seq > s.eventSeq {
+			s.eventSeq = entry.Event.Seq
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+type flakySink struct {
+	fail   bool
+	events []types.Event
+}
+
+func (f *flakySink) Deliver(event types.Event) error {
+	if f.fail {
+		return errors.New("sink is down")
+	}
+	f.events = append(f.events, event)
+	return nil
+}
+
+func TestService_RelayOutbox_retry_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.Pay(account.ID, 10_00, "Cafe")
+
+	sink := &flakySink{fail: true}
+	if svc.RelayOutbox(sink) != 0 {
+		t.Errorf("events delivered to failed sink")
+	}
+	pending := svc.PendingOutbox()
+	if len(pending) != 3 || pending[0].Attempts != 1 || pending[1].Attempts != 0 {
+		t.Errorf("wrong pending outbox => %v", pending)
+	}
+
+	sink.fail = false
+	if svc.RelayOutbox(sink) != 0 {
+		t.Errorf("events delivered before backoff")
+	}
+
+	clock.now = clock.now.Add(relayBackoff)
+	if svc.RelayOutbox(sink) != 3 {
+		t.Errorf("events not delivered after backoff")
+	}
+	for i, v := range sink.events {
+		if v.Seq != int64(i+1) {
+			t.Errorf("events out of order => %v", sink.events)
+		}
+	}
+
+	svc.PruneOutbox()
+	if len(svc.PendingOutbox()) != 0 || len(svc.outbox) != 0 {
+		t.Errorf("outbox not pruned")
+	}
+}
+
+func TestService_ExportImport_outbox_user(t *testing.T) {
+	var svc Service
+
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatalf("method Export returned not nil error, error => %v", err)
+	}
+
+	var restored Service
+	err = restored.Import(dir)
+	if err != nil {
+		t.Fatalf("method Import returned not nil error, error => %v", err)
+	}
+
+	sink := &flakySink{}
+	dedup := &DedupSink{Sink: sink}
+	svc.RelayOutbox(dedup)
+	restored.RelayOutbox(dedup)
+
+	if len(sink.events) != 3 {
+		t.Errorf("wrong delivered events => %v", sink.events)
+	}
+	if sink.events[2].Type != types.EventImported || sink.events[2].Seq != 3 {
+		t.Errorf("sequence not continued after import => %v", sink.events[2])
+	}
+}
+
+func TestService_RelayOutbox_prunesDelivered_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	if svc.RelayOutbox(&flakySink{}) != 2 || len(svc.outbox) != 0 {
+		t.Errorf("delivered events were kept => %v", len(svc.outbox))
+	}
+}
+
+func TestService_Outbox_spill_user(t *testing.T) {
+	var svc Service
+	svc.SetOutboxLimit(2)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	for i := 0; i < 3; i++ {
+		svc.Deposit(account.ID, 1_00)
+	}
+	if len(svc.PendingOutbox()) != 4 {
+		t.Errorf("events over limit were dropped without spill file => %d", len(svc.PendingOutbox()))
+	}
+
+	var spilled Service
+	spilled.SetOutboxLimit(2)
+	spilled.SetOutboxSpill(t.TempDir() + "/outbox.spill")
+	account, _ = spilled.RegisterAccount("+992000000001")
+	for i := 0; i < 4; i++ {
+		spilled.Deposit(account.ID, 1_00)
+	}
+	if len(spilled.PendingOutbox()) != 2 || spilled.OutboxSpilled() != 3 {
+		t.Fatalf("wrong outbox => %d, spilled => %d", len(spilled.PendingOutbox()), spilled.OutboxSpilled())
+	}
+
+	sink := &flakySink{}
+	for spilled.RelayOutbox(sink) > 0 {
+	}
+	if len(sink.events) != 5 || spilled.OutboxSpilled() != 0 {
+		t.Fatalf("spilled events were not relayed => %d, spilled => %d", len(sink.events), spilled.OutboxSpilled())
+	}
+	for i, v := range sink.events {
+		if v.Seq != int64(i+1) {
+			t.Errorf("events out of order => %v", sink.events)
+		}
+	}
+}
+
+//lockingSink uses service from Deliver like real sink which reads service state
+type lockingSink struct {
+	svc   *Service
+	count int64
+}
+
+func (l *lockingSink) Deliver(event types.Event) error {
+	l.svc.Locker().Lock()
+	defer l.svc.Locker().Unlock()
+	atomic.AddInt64(&l.count, 1)
+	return nil
+}
+
+func TestService_StartRelay_sinkWithoutLock_user(t *testing.T) {
+	var svc Service
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 1_00)
+
+	sink := &lockingSink{svc: &svc}
+	stop := svc.StartRelay(sink, time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&sink.count) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	if atomic.LoadInt64(&sink.count) != 2 {
+		t.Errorf("sink was called with service lock held, delivered => %d", sink.count)
+	}
+}
+
+func TestDedupSink_size_user(t *testing.T) {
+	sink := &flakySink{}
+	dedup := &DedupSink{Sink: sink, Size: 2}
+
+	for _, id := range []string{"a", "b", "a", "c", "a"} {
+		dedup.Deliver(types.Event{ID: id})
+	}
+	if len(sink.events) != 4 || len(dedup.seen) != 2 || len(dedup.order) != 2 {
+		t.Errorf("wrong delivered => %d, remembered => %d", len(sink.events), len(dedup.seen))
+	}
+}
@@ -0,0 +1,90 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrPhoneInvalid -- phone number is invalid
+var ErrPhoneInvalid = errors.New("phone number is invalid")
+
+//PhoneError describes why phone number was rejected, matches ErrPhoneInvalid with errors.Is
+type PhoneError struct {
+	Phone  string
+	Reason string
+}
+
+func (e *PhoneError) Error() string {
+	return "phone number " + e.Phone + " is invalid: " + e.Reason
+}
+
+//Unwrap returns ErrPhoneInvalid
+func (e *PhoneError) Unwrap() error {
+	return ErrPhoneInvalid
+}
+
+type phoneRule struct {
+	country  string
+	code     string
+	national int
+	trunk    string
+}
+
+//phoneRules first rule is used for numbers without country code
+var phoneRules = []phoneRule{
+	{country: "TJ", code: "992", national: 9},
+	{country: "RU", code: "7", national: 10, trunk: "8"},
+}
+
+//NormalizePhone converts phone to E.164 form like +992928393813
+func NormalizePhone(phone types.Phone) (types.Phone, error) {
+
+	raw := string(phone)
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+
+	international := false
+	if strings.HasPrefix(digits, "+") {
+		international = true
+		digits = digits[1:]
+	} else if strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+
+	if digits == "" {
+		return "", &PhoneError{Phone: raw, Reason: "empty"}
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", &PhoneError{Phone: raw, Reason: "unexpected symbol " + string(r)}
+		}
+	}
+
+	for _, rule := range phoneRules {
+		if strings.HasPrefix(digits, rule.code) && len(digits) == len(rule.code)+rule.national {
+			return types.Phone("+" + digits), nil
+		}
+	}
+	if international {
+		return "", &PhoneError{Phone: raw, Reason: "unknown country code or wrong length"}
+	}
+
+	for _, rule := range phoneRules {
+		if rule.trunk != "" && strings.HasPrefix(digits, rule.trunk) && len(digits) == len(rule.trunk)+rule.national {
+			return types.Phone("+" + rule.code + digits[len(rule.trunk):]), nil
+		}
+	}
+	if len(digits) == phoneRules[0].national {
+		return types.Phone("+" + phoneRules[0].code + digits), nil
+	}
+
+	return "", &PhoneError{Phone: raw, Reason: "wrong length"}
+}
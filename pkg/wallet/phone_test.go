@@ -0,0 +1,35 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestNormalizePhone_user(t *testing.T) {
+	valid := map[types.Phone]types.Phone{
+		"+992928393813":      "+992928393813",
+		"992928393813":       "+992928393813",
+		"92 839 38 13":       "+992928393813",
+		"00992928393813":     "+992928393813",
+		"+7 (912) 345-67-89": "+79123456789",
+		"89123456789":        "+79123456789",
+		"79123456789":        "+79123456789",
+	}
+	for phone, want := range valid {
+		got, err := NormalizePhone(phone)
+		if err != nil || got != want {
+			t.Errorf("NormalizePhone(%q) want => %v got => %v, error => %v", phone, want, got, err)
+		}
+	}
+
+	invalid := []types.Phone{"", "+", "+1234567890", "92839381", "+99292839381a", "+7912345678"}
+	for _, phone := range invalid {
+		_, err := NormalizePhone(phone)
+		var phoneErr *PhoneError
+		if !errors.Is(err, ErrPhoneInvalid) || !errors.As(err, &phoneErr) {
+			t.Errorf("NormalizePhone(%q) returned wrong error => %v", phone, err)
+		}
+	}
+}
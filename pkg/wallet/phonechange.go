@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrVerificationRequired -- operation must be confirmed with code
+var ErrVerificationRequired = errors.New("verification required")
+
+//ChangePhone binds new phone to account, fails with ErrVerificationRequired when code sender is set
+func (s *Service) ChangePhone(accountID int64, newPhone types.Phone) error {
+
+	if s.codeSender != nil {
+		return ErrVerificationRequired
+	}
+	account, phone, err := s.checkPhoneChange(accountID, newPhone)
+	if err != nil {
+		return err
+	}
+
+	s.applyPhoneChange(account, phone)
+	return nil
+}
+
+//RequestPhoneChange sends code to new phone, change is applied by ConfirmPhoneChange
+func (s *Service) RequestPhoneChange(accountID int64, newPhone types.Phone) error {
+
+	if s.codeSender == nil {
+		return ErrVerificationRequired
+	}
+	_, phone, err := s.checkPhoneChange(accountID, newPhone)
+	if err != nil {
+		return err
+	}
+
+	return s.issueCode(phoneChangeKey(accountID), phone)
+}
+
+//ConfirmPhoneChange applies requested phone change if code is right
+func (s *Service) ConfirmPhoneChange(accountID int64, code string) error {
+
+	pending, err := s.verifyCode(phoneChangeKey(accountID), code)
+	if err != nil {
+		return err
+	}
+	account, phone, err := s.checkPhoneChange(accountID, pending.phone)
+	if err != nil {
+		return err
+	}
+
+	s.applyPhoneChange(account, phone)
+	return nil
+}
+
+//PhoneHistory returns previous phones of account
+func (s *Service) PhoneHistory(accountID int64) ([]types.PhoneChange, error) {
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []types.PhoneChange
+	for _, v := range s.phoneChanges {
+		if v.AccountID == accountID {
+			changes = append(changes, *v)
+		}
+	}
+	return changes, nil
+}
+
+func (s *Service) checkPhoneChange(accountID int64, newPhone types.Phone) (*types.Account, types.Phone, error) {
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, "", err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return nil, "", err
+	}
+	phone, err := NormalizePhone(newPhone)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := s.FindAccountByPhone(phone); err == nil {
+		return nil, "", ErrPhoneRegistered
+	}
+	return account, phone, nil
+}
+
+func (s *Service) applyPhoneChange(account *types.Account, phone types.Phone) {
+
+	before := *account
+	s.phoneChanges = append(s.phoneChanges, &types.PhoneChange{
+		AccountID: account.ID,
+		OldPhone:  account.Phone,
+		NewPhone:  phone,
+		ChangedAt: s.currentTime(),
+	})
+	for _, request := range s.moneyRequests {
+		share := findShare(request, account.Phone)
+		if share != nil {
+			share.Phone = phone
+		}
+	}
+	account.Phone = phone
+	s.audit("account.phone_change", before, account)
+	s.publish(types.Event{Type: types.EventPhoneChanged, AccountID: account.ID})
+}
+
+func phoneChangeKey(accountID int64) string {
+	return fmt.Sprint("phone:", accountID)
+}
@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+type lastCodeSender struct {
+	phone types.Phone
+	code  string
+}
+
+func (s *lastCodeSender) Send(phone types.Phone, code string) error {
+	s.phone = phone
+	s.code = code
+	return nil
+}
+
+func TestService_ChangePhone_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	other, _ := svc.RegisterAccount("+992000000002")
+
+	err := svc.ChangePhone(account.ID, other.Phone)
+	if !errors.Is(err, ErrPhoneRegistered) {
+		t.Errorf("method ChangePhone returned wrong error, error => %v", err)
+	}
+
+	err = svc.ChangePhone(account.ID, "92 839 38 13")
+	if err != nil {
+		t.Fatalf("method ChangePhone returned not nil error, error => %v", err)
+	}
+	if account.Phone != "+992928393813" {
+		t.Errorf("phone not changed => %v", account.Phone)
+	}
+
+	history, _ := svc.PhoneHistory(account.ID)
+	if len(history) != 1 || history[0].OldPhone != "+992000000001" || history[0].NewPhone != account.Phone {
+		t.Errorf("wrong history => %v", history)
+	}
+
+	_, err = svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Errorf("old phone is not free, error => %v", err)
+	}
+}
+
+func TestService_ConfirmPhoneChange_user(t *testing.T) {
+	var svc Service
+	sender := &lastCodeSender{}
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+	svc.SetCodeSender(sender)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+
+	err := svc.ChangePhone(account.ID, "+992000000002")
+	if !errors.Is(err, ErrVerificationRequired) {
+		t.Errorf("method ChangePhone returned wrong error, error => %v", err)
+	}
+
+	err = svc.RequestPhoneChange(account.ID, "+992000000002")
+	if err != nil {
+		t.Fatalf("method RequestPhoneChange returned not nil error, error => %v", err)
+	}
+	if sender.phone != "+992000000002" || len(sender.code) != 6 {
+		t.Errorf("code not sent => %v", sender)
+	}
+
+	err = svc.ConfirmPhoneChange(account.ID, "wrong")
+	if !errors.Is(err, ErrCodeInvalid) {
+		t.Errorf("method ConfirmPhoneChange returned wrong error, error => %v", err)
+	}
+
+	err = svc.ConfirmPhoneChange(account.ID, sender.code)
+	if err != nil {
+		t.Fatalf("method ConfirmPhoneChange returned not nil error, error => %v", err)
+	}
+	if account.Phone != "+992000000002" {
+		t.Errorf("phone not changed => %v", account.Phone)
+	}
+
+	svc.RequestPhoneChange(account.ID, "+992000000003")
+	clock.now = clock.now.Add(defaultCodeTTL)
+	err = svc.ConfirmPhoneChange(account.ID, sender.code)
+	if !errors.Is(err, ErrCodeExpired) {
+		t.Errorf("method ConfirmPhoneChange returned wrong error, error => %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ProgressOptions tells how often progress is reported.
+//Report is called from workers one call at a time, so slow Report slows operation down.
+type ProgressOptions struct {
+	Interval time.Duration //minimal time between reports, 0 reports after every step
+	Report   func(progress types.OperationProgress)
+}
+
+func (t *tracker) watch(options ProgressOptions) {
+
+	if options.Report == nil {
+		return
+	}
+	t.options = &options
+	t.start = time.Now()
+	t.last = t.start
+}
+
+//report sends progress when interval passed since last report, final report is sent always and only once
+func (t *tracker) report(final bool, err error) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if t.finished || !final && now.Sub(t.last) < t.options.Interval {
+		return
+	}
+	t.last = now
+	t.finished = final
+
+	progress := types.OperationProgress{
+		Op:        t.op,
+		Processed: int(atomic.LoadInt64(&t.done)),
+		Total:     t.total,
+		Result:    types.Money(atomic.LoadInt64(&t.result)),
+		Matched:   int(atomic.LoadInt64(&t.matched)),
+		Elapsed:   now.Sub(t.start),
+		Done:      final,
+		Err:       err,
+	}
+	if !final && progress.Processed > 0 && progress.Processed < progress.Total {
+		progress.ETA = progress.Elapsed * time.Duration(progress.Total-progress.Processed) / time.Duration(progress.Processed)
+	}
+	t.options.Report(progress)
+}
+
+//finish sends final report with err of operation
+func (t *tracker) finish(err error) {
+
+	if t.options != nil {
+		t.report(true, err)
+	}
+}
+
+//SumPaymentsProgress is SumPaymentsContext which reports running sum
+func (s *Service) SumPaymentsProgress(ctx context.Context, goroutines int, options ProgressOptions) (types.Money, error) {
+	return s.query("sum payments", goroutines).Progress(options).Sum(ctx)
+}
+
+//FilterPaymentsByFnProgress is FilterPaymentsByFnContext which reports number of matched payments
+func (s *Service) FilterPaymentsByFnProgress(ctx context.Context, filter func(payment types.Payment) bool, goroutines int, options ProgressOptions) ([]types.Payment, error) {
+	return s.query("filter payments", goroutines).Filter(filter).Progress(options).Collect(ctx)
+}
+
+//ExportProgress is ExportContext which reports number of exported records
+func (s *Service) ExportProgress(ctx context.Context, dir string, options ProgressOptions) error {
+
+	t := newTracker(ctx, "export", 0)
+	t.watch(options)
+	err := s.exportDir(t, dir)
+	t.finish(err)
+	return err
+}
+
+//ImportProgress is ImportContext which reports number of imported lines
+func (s *Service) ImportProgress(ctx context.Context, dir string, options ProgressOptions) error {
+
+	t := newTracker(ctx, "import", 0)
+	t.watch(options)
+	err := s.importWith(t, dir)
+	t.finish(err)
+	return err
+}
+
+//progressChunk is number of payments summed for one Progress
+const progressChunk = 100_000
+
+//SumPaymentsWithProgress sends sum of every chunk of progressChunk payments in order of chunks, then closes channel.
+//Channel has room for all chunks, so it may be left unread. Use SumPaymentsProgress for processed, total and ETA.
+func (s *Service) SumPaymentsWithProgress() <-chan types.Progress {
+
+	q := s.query("sum payments", 0).ChunkSize(progressChunk)
+	_, count := q.layout()
+	ch := make(chan types.Progress, count)
+	go func() {
+		defer close(ch)
+		q.run(context.Background(), newMoneySum, func(from int, to int, r Reducer) {
+			ch <- types.Progress{Part: to - from, Result: r.(*moneySum).sum}
+		})
+	}()
+	return ch
+}
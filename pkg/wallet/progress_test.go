@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//recorder keeps reported progress, reports never overlap so it needs no lock
+type recorder struct {
+	events []types.OperationProgress
+}
+
+func (r *recorder) options(interval time.Duration) ProgressOptions {
+	return ProgressOptions{Interval: interval, Report: func(progress types.OperationProgress) {
+		r.events = append(r.events, progress)
+	}}
+}
+
+//check verifies that processed does not go back and only last event is final
+func (r *recorder) check(t *testing.T) types.OperationProgress {
+	if len(r.events) == 0 {
+		t.Fatalf("no progress reported")
+	}
+	for i, v := range r.events {
+		if v.Done != (i == len(r.events)-1) {
+			t.Errorf("event %d of %d has Done %v", i, len(r.events), v.Done)
+		}
+		if i > 0 && v.Processed < r.events[i-1].Processed {
+			t.Errorf("processed went back %d => %d", r.events[i-1].Processed, v.Processed)
+		}
+		if v.Processed > v.Total || v.ETA < 0 {
+			t.Errorf("wrong progress => %+v", v)
+		}
+	}
+	return r.events[len(r.events)-1]
+}
+
+func TestService_SumPaymentsProgress_everyStep_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 5000)
+
+	r := &recorder{}
+	sum, err := svc.SumPaymentsProgress(context.Background(), 2, r.options(0))
+	if err != nil || sum != 5000 {
+		t.Fatalf("sum => %v, error => %v", sum, err)
+	}
+	last := r.check(t)
+	if len(r.events) < 5 || last.Op != "sum payments" || last.Result != 5000 || last.Matched != 5000 {
+		t.Errorf("%d events, final progress => %+v", len(r.events), last)
+	}
+}
+
+func TestService_FilterPaymentsByFnProgress_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 100)
+
+	r := &recorder{}
+	payments, err := svc.FilterPaymentsByFnProgress(context.Background(), func(payment types.Payment) bool {
+		return payment.ID < "8"
+	}, 0, r.options(time.Hour))
+	if err != nil {
+		t.Fatalf("method FilterPaymentsByFnProgress returned not nil error, error => %v", err)
+	}
+	last := r.check(t)
+	if len(r.events) != 1 || last.Matched != len(payments) || last.Processed != 100 {
+		t.Errorf("%d events, final progress => %+v", len(r.events), last)
+	}
+}
+
+func TestService_SumPaymentsProgress_cancel_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 5000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &recorder{}
+	options := r.options(0)
+	report := options.Report
+	options.Report = func(progress types.OperationProgress) {
+		report(progress)
+		cancel()
+	}
+	_, err := svc.SumPaymentsProgress(ctx, 1, options)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled sum returned wrong error => %v", err)
+	}
+	last := r.check(t)
+	if last.Err != err || last.Processed >= last.Total {
+		t.Errorf("final progress => %+v", last)
+	}
+
+	r = &recorder{}
+	_, err = svc.SumPaymentsProgress(ctx, 1, r.options(0))
+	if last := r.check(t); len(r.events) != 1 || last.Err != err || last.Processed != 0 {
+		t.Errorf("%d events for cancelled context, final progress => %+v", len(r.events), last)
+	}
+}
+
+func TestService_ExportImportProgress_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 10)
+	dir := t.TempDir()
+
+	r := &recorder{}
+	err := svc.ExportProgress(context.Background(), dir, r.options(0))
+	if err != nil {
+		t.Fatalf("method ExportProgress returned not nil error, error => %v", err)
+	}
+	last := r.check(t)
+	if last.Op != "export" || last.Processed != last.Total || len(r.events) != last.Total+1 {
+		t.Errorf("%d events, final progress => %+v", len(r.events), last)
+	}
+
+	other := &Service{}
+	r = &recorder{}
+	err = other.ImportProgress(context.Background(), dir, r.options(time.Hour))
+	if err != nil {
+		t.Fatalf("method ImportProgress returned not nil error, error => %v", err)
+	}
+	last = r.check(t)
+	if last.Op != "import" || last.Processed != last.Total || last.Total == 0 || len(other.payments) != 10 {
+		t.Errorf("final progress => %+v", last)
+	}
+}
@@ -0,0 +1,331 @@
+package wallet
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//chunksPerWorker is how many chunks every worker gets when chunk size is not set, so slow chunks do not stall others
+const chunksPerWorker = 4
+
+//Reducer folds payments of one chunk, Merge adds result of other chunk to it
+type Reducer interface {
+	Add(payment types.Payment)
+	Merge(other Reducer)
+}
+
+//Query is parallel map/filter/reduce over payments.
+//Every method returns new query, so query can be reused and extended.
+type Query struct {
+	payments   []*types.Payment
+	goroutines int
+	chunkSize  int
+	ordered    bool
+	op         string
+	stages     []stage
+	progress   *ProgressOptions
+}
+
+//stage is filter or map, filter drops payment when it returns false
+type stage struct {
+	filter func(payment types.Payment) bool
+	mapper func(payment types.Payment) types.Payment
+}
+
+//Query starts query over all payments, service must not be changed until query is finished
+func (s *Service) Query() *Query {
+	return &Query{payments: s.payments, op: "query"}
+}
+
+func (s *Service) query(op string, goroutines int) *Query {
+
+	q := s.Query().Goroutines(goroutines).Ordered()
+	q.op = op
+	return q
+}
+
+func (q *Query) clone() *Query {
+
+	c := *q
+	c.stages = append([]stage(nil), q.stages...)
+	return &c
+}
+
+//Goroutines sets number of workers, 0 or less means runtime.NumCPU()
+func (q *Query) Goroutines(n int) *Query {
+
+	c := q.clone()
+	c.goroutines = n
+	return c
+}
+
+//ChunkSize sets number of payments in chunk, 0 or less means size chosen by number of workers
+func (q *Query) ChunkSize(n int) *Query {
+
+	c := q.clone()
+	c.chunkSize = n
+	return c
+}
+
+//Ordered keeps order of payments in results and merges chunks in their order.
+//Without it chunks are merged as they finish, so Merge of reducer must be commutative.
+func (q *Query) Ordered() *Query {
+
+	c := q.clone()
+	c.ordered = true
+	return c
+}
+
+//Progress reports progress of query to options.Report, final event is sent even when query fails
+func (q *Query) Progress(options ProgressOptions) *Query {
+
+	c := q.clone()
+	c.progress = &options
+	return c
+}
+
+//Filter keeps payments for which fn returns true
+func (q *Query) Filter(fn func(payment types.Payment) bool) *Query {
+
+	c := q.clone()
+	c.stages = append(c.stages, stage{filter: fn})
+	return c
+}
+
+//Map replaces every payment with result of fn, stored payments are not changed
+func (q *Query) Map(fn func(payment types.Payment) types.Payment) *Query {
+
+	c := q.clone()
+	c.stages = append(c.stages, stage{mapper: fn})
+	return c
+}
+
+//Collect returns payments left after filters and maps, nil when there are none
+func (q *Query) Collect(ctx context.Context) ([]types.Payment, error) {
+
+	r, err := q.Reduce(ctx, func() Reducer { return &collector{} })
+	if err != nil {
+		return nil, err
+	}
+	return r.(*collector).payments, nil
+}
+
+//Count returns number of payments left after filters
+func (q *Query) Count(ctx context.Context) (int, error) {
+
+	r, err := q.Reduce(ctx, func() Reducer { return &counter{} })
+	if err != nil {
+		return 0, err
+	}
+	return r.(*counter).count, nil
+}
+
+//Sum returns sum of amounts of payments left after filters and maps
+func (q *Query) Sum(ctx context.Context) (types.Money, error) {
+
+	r, err := q.Reduce(ctx, newMoneySum)
+	if err != nil {
+		return 0, err
+	}
+	return r.(*moneySum).sum, nil
+}
+
+//Reduce folds every chunk with new reducer and merges them into first one.
+//On cancellation it returns CancelError with number of processed payments.
+func (q *Query) Reduce(ctx context.Context, newReducer func() Reducer) (Reducer, error) {
+
+	var result Reducer
+	err := q.run(ctx, newReducer, func(from int, to int, r Reducer) {
+		if result == nil {
+			result = r
+			return
+		}
+		result.Merge(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = newReducer()
+	}
+	return result, nil
+}
+
+//layout returns size and number of chunks
+func (q *Query) layout() (int, int) {
+
+	n := len(q.payments)
+	if n == 0 {
+		return 0, 0
+	}
+	size := q.chunkSize
+	if size < 1 {
+		chunks := q.workers() * chunksPerWorker
+		size = (n + chunks - 1) / chunks
+	}
+	return size, (n + size - 1) / size
+}
+
+func (q *Query) workers() int {
+
+	if q.goroutines < 1 {
+		return runtime.NumCPU()
+	}
+	return q.goroutines
+}
+
+//run folds chunks in workers and calls done for every chunk under lock, in order of chunks when query is ordered
+func (q *Query) run(ctx context.Context, newReducer func() Reducer, done func(from int, to int, r Reducer)) (err error) {
+
+	t := newTracker(ctx, q.op, len(q.payments))
+	if q.progress != nil {
+		t.watch(*q.progress)
+		defer func() { t.finish(err) }()
+	}
+	if err := t.check(); err != nil {
+		return err
+	}
+	size, count := q.layout()
+	workers := q.workers()
+	if workers > count {
+		workers = count
+	}
+
+	bounds := func(chunk int) (int, int) {
+		to := (chunk + 1) * size
+		if to > len(q.payments) {
+			to = len(q.payments)
+		}
+		return chunk * size, to
+	}
+
+	mu := sync.Mutex{}
+	results := make([]Reducer, count)
+	merged := 0
+	next := int64(-1)
+	errs := make([]error, workers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				chunk := int(atomic.AddInt64(&next, 1))
+				if chunk >= count {
+					return
+				}
+				from, to := bounds(chunk)
+				r, err := q.fold(t, from, to, newReducer())
+				if err != nil {
+					errs[worker] = err
+					return
+				}
+
+				mu.Lock()
+				if !q.ordered {
+					done(from, to, r)
+				} else {
+					results[chunk] = r
+					for merged < count && results[merged] != nil {
+						from, to := bounds(merged)
+						done(from, to, results[merged])
+						results[merged] = nil
+						merged++
+					}
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Query) fold(t *tracker, from int, to int, r Reducer) (Reducer, error) {
+
+	for i := from; i < to; i += checkEvery {
+		if err := t.check(); err != nil {
+			return nil, err
+		}
+		end := i + checkEvery
+		if end > to {
+			end = to
+		}
+		matched := 0
+		amount := types.Money(0)
+		for _, v := range q.payments[i:end] {
+			payment, ok := q.apply(*v)
+			if ok {
+				r.Add(payment)
+				matched++
+				amount += payment.Amount
+			}
+		}
+		t.addResult(matched, amount)
+		t.add(end - i)
+	}
+	return r, nil
+}
+
+func (q *Query) apply(payment types.Payment) (types.Payment, bool) {
+
+	for _, v := range q.stages {
+		if v.filter != nil && !v.filter(payment) {
+			return payment, false
+		}
+		if v.mapper != nil {
+			payment = v.mapper(payment)
+		}
+	}
+	return payment, true
+}
+
+type collector struct {
+	payments []types.Payment
+}
+
+func (c *collector) Add(payment types.Payment) {
+	c.payments = append(c.payments, payment)
+}
+
+func (c *collector) Merge(other Reducer) {
+	c.payments = append(c.payments, other.(*collector).payments...)
+}
+
+type counter struct {
+	count int
+}
+
+func (c *counter) Add(payment types.Payment) {
+	c.count++
+}
+
+func (c *counter) Merge(other Reducer) {
+	c.count += other.(*counter).count
+}
+
+type moneySum struct {
+	sum types.Money
+}
+
+func newMoneySum() Reducer {
+	return &moneySum{}
+}
+
+func (m *moneySum) Add(payment types.Payment) {
+	m.sum += payment.Amount
+}
+
+func (m *moneySum) Merge(other Reducer) {
+	m.sum += other.(*moneySum).sum
+}
@@ -0,0 +1,199 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/quick"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//queryCase is random input of property tests
+type queryCase struct {
+	Amounts    []int16
+	Goroutines uint8
+	ChunkSize  uint8
+	Mod        uint8
+	Ordered    bool
+}
+
+func (c queryCase) query() *Query {
+
+	q := &Query{op: "query"}
+	for i, v := range c.Amounts {
+		q.payments = append(q.payments, &types.Payment{
+			ID:        fmt.Sprint(i),
+			AccountID: int64(i % 3),
+			Amount:    types.Money(v),
+			Category:  "Cafe",
+		})
+	}
+	q = q.Goroutines(int(c.Goroutines % 9)).ChunkSize(int(c.ChunkSize % 40))
+	if c.Ordered {
+		q = q.Ordered()
+	}
+	return q
+}
+
+func (c queryCase) keep(payment types.Payment) bool {
+	return c.Mod == 0 || int64(payment.Amount)%int64(c.Mod) != 0
+}
+
+func double(payment types.Payment) types.Payment {
+	payment.Amount *= 2
+	return payment
+}
+
+//reference is sequential filter and map of payments
+func (c queryCase) reference(q *Query) []types.Payment {
+
+	var payments []types.Payment
+	for _, v := range q.payments {
+		if c.keep(*v) {
+			payments = append(payments, double(*v))
+		}
+	}
+	return payments
+}
+
+func TestQuery_Collect_matchesSequential_user(t *testing.T) {
+	property := func(c queryCase) bool {
+		q := c.query()
+		got, err := q.Filter(c.keep).Map(double).Collect(context.Background())
+		if err != nil {
+			return false
+		}
+		want := c.reference(q)
+		if !c.Ordered {
+			sort.Slice(got, func(i, j int) bool { return len(got[i].ID) < len(got[j].ID) || len(got[i].ID) == len(got[j].ID) && got[i].ID < got[j].ID })
+		}
+		return reflect.DeepEqual(got, want)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuery_SumCount_matchesSequential_user(t *testing.T) {
+	property := func(c queryCase) bool {
+		q := c.query().Filter(c.keep).Map(double)
+		sum, err := q.Sum(context.Background())
+		if err != nil {
+			return false
+		}
+		count, err := q.Count(context.Background())
+		if err != nil {
+			return false
+		}
+		want := types.Money(0)
+		reference := c.reference(q)
+		for _, v := range reference {
+			want += v.Amount
+		}
+		return sum == want && count == len(reference)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+//firstIDs keeps IDs in order chunks were merged, so it is not commutative
+type firstIDs struct {
+	ids []string
+}
+
+func (f *firstIDs) Add(payment types.Payment) {
+	f.ids = append(f.ids, payment.ID)
+}
+
+func (f *firstIDs) Merge(other Reducer) {
+	f.ids = append(f.ids, other.(*firstIDs).ids...)
+}
+
+func TestQuery_Reduce_orderedMerge_user(t *testing.T) {
+	property := func(c queryCase) bool {
+		c.Ordered = true
+		q := c.query()
+		r, err := q.Reduce(context.Background(), func() Reducer { return &firstIDs{} })
+		if err != nil {
+			return false
+		}
+		var want []string
+		for _, v := range q.payments {
+			want = append(want, v.ID)
+		}
+		return reflect.DeepEqual(r.(*firstIDs).ids, want)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuery_layout_user(t *testing.T) {
+	q := &Query{payments: make([]*types.Payment, 10)}
+
+	if size, count := q.ChunkSize(3).layout(); size != 3 || count != 4 {
+		t.Errorf("chunk size 3 => size %d, count %d", size, count)
+	}
+	if size, count := q.Goroutines(2).layout(); size != 2 || count != 5 {
+		t.Errorf("2 goroutines => size %d, count %d", size, count)
+	}
+	if _, count := (&Query{}).layout(); count != 0 {
+		t.Errorf("empty query has %d chunks", count)
+	}
+}
+
+func TestQuery_doesNotChangeParent_user(t *testing.T) {
+	q := (&Query{payments: []*types.Payment{{ID: "1", Amount: 1}, {ID: "2", Amount: 2}}}).Goroutines(1)
+	q.Filter(func(payment types.Payment) bool { return false })
+
+	sum, err := q.Map(double).Sum(context.Background())
+	if err != nil || sum != 6 {
+		t.Errorf("sum => %v, error => %v", sum, err)
+	}
+	if q.payments[0].Amount != 1 {
+		t.Errorf("map changed stored payment => %v", q.payments[0])
+	}
+}
+
+func TestService_SumPayments_autoGoroutines_user(t *testing.T) {
+	svc := newServiceWithPayments(t, 100)
+
+	for _, goroutines := range []int{-1, 0, 1, 7, 1000} {
+		if got := svc.SumPayments(goroutines); got != 100 {
+			t.Errorf("goroutines %d: sum => %v", goroutines, got)
+		}
+	}
+}
+
+func TestService_SumPaymentsWithProgress_allChunks_user(t *testing.T) {
+	var svc Service
+	for i := 0; i < 2*progressChunk+5; i++ {
+		svc.payments = append(svc.payments, &types.Payment{Amount: 1})
+	}
+
+	var parts []int
+	sum := types.Money(0)
+	for v := range svc.SumPaymentsWithProgress() {
+		parts = append(parts, v.Part)
+		sum += v.Result
+	}
+	if !reflect.DeepEqual(parts, []int{progressChunk, progressChunk, 5}) || sum != 2*progressChunk+5 {
+		t.Errorf("progress parts => %v, sum => %v", parts, sum)
+	}
+}
+
+func TestService_SumPaymentsWithProgress_unread_user(t *testing.T) {
+	var svc Service
+	for i := 0; i < 2*progressChunk+5; i++ {
+		svc.payments = append(svc.payments, &types.Payment{Amount: 1})
+	}
+
+	// sender never blocks when channel has room for every chunk
+	if ch := svc.SumPaymentsWithProgress(); cap(ch) != 3 {
+		t.Errorf("channel has no room for all chunks, cap => %d", cap(ch))
+	}
+}
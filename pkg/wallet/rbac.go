@@ -0,0 +1,820 @@
+package wallet
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//Permission names operation checked by Access
+type Permission string
+
+//Permissions of Access
+const (
+	PermAccountRegister Permission = "account.register"
+	PermAccountRead     Permission = "account.read"
+	PermAccountDeposit  Permission = "account.deposit"
+	PermAccountManage   Permission = "account.manage"
+	PermAccountProfile  Permission = "account.profile"
+	PermPaymentPay      Permission = "payment.pay"
+	PermPaymentRead     Permission = "payment.read"
+	PermPaymentReject   Permission = "payment.reject"
+	PermPaymentConfirm  Permission = "payment.confirm"
+	PermFavoriteManage  Permission = "favorite.manage"
+	PermDataExport      Permission = "data.export"
+	PermDataImport      Permission = "data.import"
+	PermReportRead      Permission = "report.read"
+	PermAuditRead       Permission = "audit.read"
+	PermSystemManage    Permission = "system.manage"
+)
+
+type scope int
+
+const (
+	scopeNone scope = iota
+	scopeOwn
+	scopeAny
+)
+
+var rolePermissions = map[types.Role]map[Permission]scope{
+	types.RoleCustomer: {
+		PermAccountRead:    scopeOwn,
+		PermAccountProfile: scopeOwn,
+		PermPaymentPay:     scopeOwn,
+		PermPaymentRead:    scopeOwn,
+		PermFavoriteManage: scopeOwn,
+	},
+	types.RoleSupport: {
+		PermAccountRegister: scopeAny,
+		PermAccountRead:     scopeAny,
+		PermAccountDeposit:  scopeAny,
+		PermAccountManage:   scopeAny,
+		PermPaymentRead:     scopeAny,
+		PermPaymentReject:   scopeAny,
+		PermPaymentConfirm:  scopeAny,
+	},
+	types.RoleAuditor: {
+		PermAccountRead: scopeAny,
+		PermPaymentRead: scopeAny,
+		PermDataExport:  scopeAny,
+		PermReportRead:  scopeAny,
+		PermAuditRead:   scopeAny,
+	},
+	types.RoleAdmin: {
+		PermAccountRegister: scopeAny,
+		PermAccountRead:     scopeAny,
+		PermAccountDeposit:  scopeAny,
+		PermAccountManage:   scopeAny,
+		PermAccountProfile:  scopeAny,
+		PermPaymentPay:      scopeAny,
+		PermPaymentRead:     scopeAny,
+		PermPaymentReject:   scopeAny,
+		PermPaymentConfirm:  scopeAny,
+		PermFavoriteManage:  scopeAny,
+		PermDataExport:      scopeAny,
+		PermDataImport:      scopeAny,
+		PermReportRead:      scopeAny,
+		PermAuditRead:       scopeAny,
+		PermSystemManage:    scopeAny,
+	},
+}
+
+//Access checks role of actor before calling Service, it returns copies, so caller can not change stored data.
+//Methods which set service up or run its background work (Set..., Start..., Subscribe, Locker, Context and
+//Progress variants) and methods of authentication (Authenticate, Session, Logout) are not wrapped:
+//they are called by program which owns Service, not on behalf of actor.
+type Access struct {
+	svc   *Service
+	actor types.Actor
+}
+
+//As returns Access of actor
+func (s *Service) As(actor types.Actor) *Access {
+	return &Access{svc: s, actor: actor}
+}
+
+//AccessDenials returns denied attempts, denials are recorded under own lock, so it can be called without Locker
+func (s *Service) AccessDenials() []types.AccessDenial {
+
+	s.denialsMu.Lock()
+	defer s.denialsMu.Unlock()
+	var denials []types.AccessDenial
+	for _, v := range s.denials {
+		denials = append(denials, *v)
+	}
+	return denials
+}
+
+//Can reports if actor has permission on account, accountID 0 means no account
+func (a *Access) Can(perm Permission, accountID int64) bool {
+
+	switch rolePermissions[a.actor.Role][perm] {
+	case scopeAny:
+		return true
+	case scopeOwn:
+		return accountID != 0 && accountID == a.actor.AccountID
+	}
+	return false
+}
+
+func (a *Access) check(perm Permission, accountID int64) error {
+
+	if a.Can(perm, accountID) {
+		return nil
+	}
+	denial := &types.AccessDenial{
+		Actor:      a.actor,
+		Permission: string(perm),
+		AccountID:  accountID,
+		At:         a.svc.currentTime(),
+	}
+	a.svc.denialsMu.Lock()
+	a.svc.denials = append(a.svc.denials, denial)
+	a.svc.denialsMu.Unlock()
+	return ErrForbidden
+}
+
+func (a *Access) checkPayment(perm Permission, paymentID string) error {
+
+	payment, err := a.svc.FindPaymentByID(paymentID)
+	if err != nil {
+		if a.Can(perm, 0) {
+			return err
+		}
+		return a.check(perm, 0)
+	}
+	return a.check(perm, payment.AccountID)
+}
+
+func (a *Access) checkSchedule(perm Permission, scheduleID string) error {
+
+	schedule, err := a.svc.FindScheduleByID(scheduleID)
+	if err == nil {
+		var favorite *types.Favorite
+		favorite, err = a.svc.findFavorite(schedule.FavoriteID)
+		if err == nil {
+			return a.check(perm, favorite.AccountID)
+		}
+	}
+	if a.Can(perm, 0) {
+		return err
+	}
+	return a.check(perm, 0)
+}
+
+func (a *Access) checkFavorite(perm Permission, favoriteID string) error {
+
+	favorite, err := a.svc.findFavorite(favoriteID)
+	if err != nil {
+		if a.Can(perm, 0) {
+			return err
+		}
+		return a.check(perm, 0)
+	}
+	return a.check(perm, favorite.AccountID)
+}
+
+//RegisterAccount method
+func (a *Access) RegisterAccount(phone types.Phone) (*types.Account, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRegister, 0); err != nil {
+		return nil, err
+	}
+	return copyAccount(a.svc.RegisterAccount(phone))
+}
+
+//FindAccountByID method
+func (a *Access) FindAccountByID(accountID int64) (*types.Account, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return nil, err
+	}
+	return copyAccount(a.svc.FindAccountByID(accountID))
+}
+
+//Deposit method
+func (a *Access) Deposit(accountID int64, amount types.Money) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountDeposit, accountID); err != nil {
+		return err
+	}
+	return a.svc.Deposit(accountID, amount)
+}
+
+//FreezeAccount method
+func (a *Access) FreezeAccount(accountID int64) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountManage, accountID); err != nil {
+		return err
+	}
+	return a.svc.FreezeAccount(accountID)
+}
+
+//UnfreezeAccount method
+func (a *Access) UnfreezeAccount(accountID int64) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountManage, accountID); err != nil {
+		return err
+	}
+	return a.svc.UnfreezeAccount(accountID)
+}
+
+//CloseAccount method
+func (a *Access) CloseAccount(accountID int64, sweepToID int64) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountManage, accountID); err != nil {
+		return err
+	}
+	return a.svc.CloseAccount(accountID, sweepToID)
+}
+
+//Pay method
+func (a *Access) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentPay, accountID); err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.Pay(accountID, amount, category))
+}
+
+//FindPaymentByID method
+func (a *Access) FindPaymentByID(paymentID string) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkPayment(PermPaymentRead, paymentID); err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.FindPaymentByID(paymentID))
+}
+
+//Reject method
+func (a *Access) Reject(paymentID string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkPayment(PermPaymentReject, paymentID); err != nil {
+		return err
+	}
+	return a.svc.Reject(paymentID)
+}
+
+//Repeat method
+func (a *Access) Repeat(paymentID string) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkPayment(PermPaymentPay, paymentID); err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.Repeat(paymentID))
+}
+
+//FavoritePayment method
+func (a *Access) FavoritePayment(paymentID string, name string) (*types.Favorite, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkPayment(PermFavoriteManage, paymentID); err != nil {
+		return nil, err
+	}
+	return copyFavorite(a.svc.FavoritePayment(paymentID, name))
+}
+
+//PayFromFavorite method
+func (a *Access) PayFromFavorite(favoriteID string) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkFavorite(PermFavoriteManage, favoriteID); err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.PayFromFavorite(favoriteID))
+}
+
+//ExportAccountHistory method
+func (a *Access) ExportAccountHistory(accountID int64) ([]types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentRead, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.ExportAccountHistory(accountID)
+}
+
+//Export method
+func (a *Access) Export(dir string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermDataExport, 0); err != nil {
+		return err
+	}
+	return a.svc.Export(dir)
+}
+
+//Import method
+func (a *Access) Import(dir string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermDataImport, 0); err != nil {
+		return err
+	}
+	return a.svc.Import(dir)
+}
+
+//SumPayments method
+func (a *Access) SumPayments(goroutines int) (types.Money, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermReportRead, 0); err != nil {
+		return 0, err
+	}
+	return a.svc.SumPayments(goroutines), nil
+}
+
+//FilterPayments method
+func (a *Access) FilterPayments(accountID int64, goroutines int) ([]types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentRead, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.FilterPayments(accountID, goroutines)
+}
+
+//Confirm method
+func (a *Access) Confirm(paymentID string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkPayment(PermPaymentConfirm, paymentID); err != nil {
+		return err
+	}
+	return a.svc.Confirm(paymentID)
+}
+
+//RedeemPoints method
+func (a *Access) RedeemPoints(accountID int64, points types.Money) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentPay, accountID); err != nil {
+		return err
+	}
+	return a.svc.RedeemPoints(accountID, points)
+}
+
+//ScheduleFavorite method
+func (a *Access) ScheduleFavorite(favoriteID string, kind types.ScheduleKind, start time.Time) (*types.Schedule, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkFavorite(PermFavoriteManage, favoriteID); err != nil {
+		return nil, err
+	}
+	return copySchedule(a.svc.ScheduleFavorite(favoriteID, kind, start))
+}
+
+//UpdateFavorite method
+func (a *Access) UpdateFavorite(accountID int64, favoriteID string, name string, amount types.Money) (*types.Favorite, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermFavoriteManage, accountID); err != nil {
+		return nil, err
+	}
+	return copyFavorite(a.svc.UpdateFavorite(accountID, favoriteID, name, amount))
+}
+
+//DeleteFavorite method
+func (a *Access) DeleteFavorite(accountID int64, favoriteID string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermFavoriteManage, accountID); err != nil {
+		return err
+	}
+	return a.svc.DeleteFavorite(accountID, favoriteID)
+}
+
+//PayBatch checks every account of batch before any item is paid
+func (a *Access) PayBatch(items []types.BatchItem, mode types.BatchMode, goroutines int) ([]BatchResult, error) {
+	defer a.svc.actingAs(a.actor)()
+	for _, v := range items {
+		if err := a.check(PermPaymentPay, v.AccountID); err != nil {
+			return nil, err
+		}
+	}
+	results, err := a.svc.PayBatch(items, mode, goroutines)
+	for i, v := range results {
+		if v.Payment != nil {
+			results[i].Payment, _ = copyPayment(v.Payment, nil)
+		}
+	}
+	return results, err
+}
+
+//ChangePhone method
+func (a *Access) ChangePhone(accountID int64, newPhone types.Phone) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountProfile, accountID); err != nil {
+		return err
+	}
+	return a.svc.ChangePhone(accountID, newPhone)
+}
+
+//ConfirmPhoneChange method
+func (a *Access) ConfirmPhoneChange(accountID int64, code string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountProfile, accountID); err != nil {
+		return err
+	}
+	return a.svc.ConfirmPhoneChange(accountID, code)
+}
+
+//SetPIN method
+func (a *Access) SetPIN(accountID int64, pin string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountProfile, accountID); err != nil {
+		return err
+	}
+	return a.svc.SetPIN(accountID, pin)
+}
+
+//RequestMoney method
+func (a *Access) RequestMoney(accountID int64, category types.PaymentCategory, shares []types.MoneyRequestShare, ttl time.Duration) (*types.MoneyRequest, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentPay, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.RequestMoney(accountID, category, shares, ttl)
+}
+
+//HistoryToFiles writes payments to files, so it needs export permission
+func (a *Access) HistoryToFiles(payments []types.Payment, dir string, records int) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermDataExport, 0); err != nil {
+		return err
+	}
+	return a.svc.HistoryToFiles(payments, dir, records)
+}
+
+//Accounts method
+func (a *Access) Accounts() ([]types.Account, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.Accounts(), nil
+}
+
+//FindAccountByPhone method
+func (a *Access) FindAccountByPhone(phone types.Phone) (*types.Account, error) {
+	defer a.svc.actingAs(a.actor)()
+	account, err := a.svc.FindAccountByPhone(phone)
+	if err != nil {
+		if a.Can(PermAccountRead, 0) {
+			return nil, err
+		}
+		return nil, a.check(PermAccountRead, 0)
+	}
+	if err := a.check(PermAccountRead, account.ID); err != nil {
+		return nil, err
+	}
+	return copyAccount(account, nil)
+}
+
+//ExportToFile method
+func (a *Access) ExportToFile(path string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermDataExport, 0); err != nil {
+		return err
+	}
+	return a.svc.ExportToFile(path)
+}
+
+//ImportFromFile method
+func (a *Access) ImportFromFile(path string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermDataImport, 0); err != nil {
+		return err
+	}
+	return a.svc.ImportFromFile(path)
+}
+
+//FilterPaymentsByFn reads payments of all accounts, so it needs report permission
+func (a *Access) FilterPaymentsByFn(filter func(payment types.Payment) bool, goroutines int) ([]types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermReportRead, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.FilterPaymentsByFn(filter, goroutines)
+}
+
+//Query reads payments of all accounts, so it needs report permission
+func (a *Access) Query() (*Query, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermReportRead, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.Query(), nil
+}
+
+//AuditLog method
+func (a *Access) AuditLog() ([]types.AuditRecord, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAuditRead, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.AuditLog(), nil
+}
+
+//AuditHead method
+func (a *Access) AuditHead() (string, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAuditRead, 0); err != nil {
+		return "", err
+	}
+	return a.svc.AuditHead(), nil
+}
+
+//AccountFavorites method
+func (a *Access) AccountFavorites(accountID int64) ([]types.Favorite, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.AccountFavorites(accountID)
+}
+
+//FindFavoriteByID method
+func (a *Access) FindFavoriteByID(accountID int64, favoriteID string) (*types.Favorite, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return nil, err
+	}
+	return copyFavorite(a.svc.FindFavoriteByID(accountID, favoriteID))
+}
+
+//AddRewardRule method
+func (a *Access) AddRewardRule(category types.PaymentCategory, kind types.RewardKind, percent int64, monthlyCap types.Money) (*types.RewardRule, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return nil, err
+	}
+	rule, err := a.svc.AddRewardRule(category, kind, percent, monthlyCap)
+	if err != nil {
+		return nil, err
+	}
+	copied := *rule
+	return &copied, nil
+}
+
+//AccountRewards method
+func (a *Access) AccountRewards(accountID int64) ([]types.Reward, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.AccountRewards(accountID)
+}
+
+//Points method
+func (a *Access) Points(accountID int64) (types.Money, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return 0, err
+	}
+	return a.svc.Points(accountID)
+}
+
+//FindScheduleByID method
+func (a *Access) FindScheduleByID(scheduleID string) (*types.Schedule, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkSchedule(PermAccountRead, scheduleID); err != nil {
+		return nil, err
+	}
+	return copySchedule(a.svc.FindScheduleByID(scheduleID))
+}
+
+//ScheduleHistory method
+func (a *Access) ScheduleHistory(scheduleID string) ([]types.ScheduleExecution, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkSchedule(PermAccountRead, scheduleID); err != nil {
+		return nil, err
+	}
+	return a.svc.ScheduleHistory(scheduleID)
+}
+
+//SetScheduleRetry method
+func (a *Access) SetScheduleRetry(scheduleID string, maxRetries int, interval time.Duration) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkSchedule(PermFavoriteManage, scheduleID); err != nil {
+		return err
+	}
+	return a.svc.SetScheduleRetry(scheduleID, maxRetries, interval)
+}
+
+//PauseSchedule method
+func (a *Access) PauseSchedule(scheduleID string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkSchedule(PermFavoriteManage, scheduleID); err != nil {
+		return err
+	}
+	return a.svc.PauseSchedule(scheduleID)
+}
+
+//ResumeSchedule method
+func (a *Access) ResumeSchedule(scheduleID string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.checkSchedule(PermFavoriteManage, scheduleID); err != nil {
+		return err
+	}
+	return a.svc.ResumeSchedule(scheduleID)
+}
+
+//RunSchedules method
+func (a *Access) RunSchedules() ([]types.ScheduleExecution, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.RunSchedules(), nil
+}
+
+//FindMoneyRequest method
+func (a *Access) FindMoneyRequest(accountID int64, requestID string) (*types.MoneyRequest, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.FindMoneyRequest(accountID, requestID)
+}
+
+//IncomingMoneyRequests method
+func (a *Access) IncomingMoneyRequests(accountID int64) ([]types.MoneyRequest, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.IncomingMoneyRequests(accountID)
+}
+
+//AcceptMoneyRequest pays share from account, so it needs pay permission
+func (a *Access) AcceptMoneyRequest(accountID int64, requestID string) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentPay, accountID); err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.AcceptMoneyRequest(accountID, requestID))
+}
+
+//DeclineMoneyRequest method
+func (a *Access) DeclineMoneyRequest(accountID int64, requestID string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentPay, accountID); err != nil {
+		return err
+	}
+	return a.svc.DeclineMoneyRequest(accountID, requestID)
+}
+
+//ExpireMoneyRequests method
+func (a *Access) ExpireMoneyRequests() error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return err
+	}
+	a.svc.ExpireMoneyRequests()
+	return nil
+}
+
+//ChangePIN method
+func (a *Access) ChangePIN(accountID int64, oldPIN string, newPIN string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountProfile, accountID); err != nil {
+		return err
+	}
+	return a.svc.ChangePIN(accountID, oldPIN, newPIN)
+}
+
+//RequestPhoneChange method
+func (a *Access) RequestPhoneChange(accountID int64, newPhone types.Phone) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountProfile, accountID); err != nil {
+		return err
+	}
+	return a.svc.RequestPhoneChange(accountID, newPhone)
+}
+
+//PhoneHistory method
+func (a *Access) PhoneHistory(accountID int64) ([]types.PhoneChange, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return nil, err
+	}
+	return a.svc.PhoneHistory(accountID)
+}
+
+//SendVerificationCode method
+func (a *Access) SendVerificationCode(accountID int64) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountProfile, accountID); err != nil {
+		return err
+	}
+	return a.svc.SendVerificationCode(accountID)
+}
+
+//VerifyAccount method
+func (a *Access) VerifyAccount(accountID int64, code string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountProfile, accountID); err != nil {
+		return err
+	}
+	return a.svc.VerifyAccount(accountID, code)
+}
+
+//IsVerified method
+func (a *Access) IsVerified(accountID int64) (bool, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermAccountRead, accountID); err != nil {
+		return false, err
+	}
+	return a.svc.IsVerified(accountID)
+}
+
+//SendPaymentCode method
+func (a *Access) SendPaymentCode(accountID int64, amount types.Money, category types.PaymentCategory) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentPay, accountID); err != nil {
+		return err
+	}
+	return a.svc.SendPaymentCode(accountID, amount, category)
+}
+
+//PayWithCode method
+func (a *Access) PayWithCode(accountID int64, amount types.Money, category types.PaymentCategory, code string) (*types.Payment, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermPaymentPay, accountID); err != nil {
+		return nil, err
+	}
+	return copyPayment(a.svc.PayWithCode(accountID, amount, category, code))
+}
+
+//RegisterWebhook method
+func (a *Access) RegisterWebhook(endpoint string, secret string, accountID int64, category types.PaymentCategory) (*types.Webhook, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.RegisterWebhook(endpoint, secret, accountID, category)
+}
+
+//RemoveWebhook method
+func (a *Access) RemoveWebhook(webhookID string) error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return err
+	}
+	return a.svc.RemoveWebhook(webhookID)
+}
+
+//DeliverWebhooks method
+func (a *Access) DeliverWebhooks(client *http.Client) (int, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return 0, err
+	}
+	return a.svc.DeliverWebhooks(client), nil
+}
+
+//WebhookDeadLetters method
+func (a *Access) WebhookDeadLetters() ([]types.WebhookDelivery, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.WebhookDeadLetters(), nil
+}
+
+//RelayOutbox method
+func (a *Access) RelayOutbox(sink EventSink) (int, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return 0, err
+	}
+	return a.svc.RelayOutbox(sink), nil
+}
+
+//PendingOutbox method
+func (a *Access) PendingOutbox() ([]types.OutboxEntry, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return nil, err
+	}
+	return a.svc.PendingOutbox(), nil
+}
+
+//PruneOutbox method
+func (a *Access) PruneOutbox() error {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return err
+	}
+	a.svc.PruneOutbox()
+	return nil
+}
+
+//OutboxSpilled method
+func (a *Access) OutboxSpilled() (int, error) {
+	defer a.svc.actingAs(a.actor)()
+	if err := a.check(PermSystemManage, 0); err != nil {
+		return 0, err
+	}
+	return a.svc.OutboxSpilled(), nil
+}
+
+//copySchedule is copyAccount for schedule
+func copySchedule(schedule *types.Schedule, err error) (*types.Schedule, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	copied := *schedule
+	return &copied, nil
+}
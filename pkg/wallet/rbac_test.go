@@ -0,0 +1,232 @@
+package wallet
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestAccess_roles_user(t *testing.T) {
+	var svc Service
+
+	support := svc.As(types.Actor{Name: "sam", Role: types.RoleSupport})
+	account, err := support.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatalf("method RegisterAccount returned not nil error, error => %v", err)
+	}
+	other, _ := support.RegisterAccount("+992000000002")
+	support.Deposit(account.ID, 100_00)
+	support.Deposit(other.ID, 100_00)
+
+	customer := svc.As(types.Actor{Name: "bob", Role: types.RoleCustomer, AccountID: account.ID})
+	payment, err := customer.Pay(account.ID, 10_00, "Cafe")
+	if err != nil {
+		t.Fatalf("method Pay returned not nil error, error => %v", err)
+	}
+	_, err = customer.Pay(other.ID, 10_00, "Cafe")
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("customer paid from other account, error => %v", err)
+	}
+	err = customer.Reject(payment.ID)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("customer rejected payment, error => %v", err)
+	}
+	err = customer.Deposit(account.ID, 100_00)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("customer made deposit, error => %v", err)
+	}
+
+	err = support.Reject(payment.ID)
+	if err != nil {
+		t.Errorf("method Reject returned not nil error, error => %v", err)
+	}
+	_, err = support.Pay(account.ID, 10_00, "Cafe")
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("support made payment, error => %v", err)
+	}
+
+	auditor := svc.As(types.Actor{Name: "ann", Role: types.RoleAuditor})
+	if _, err := auditor.FindPaymentByID(payment.ID); err != nil {
+		t.Errorf("auditor can not read payment, error => %v", err)
+	}
+	if err := auditor.Import("data"); err != ErrForbidden {
+		t.Errorf("auditor imported data, error => %v", err)
+	}
+
+	denials := svc.AccessDenials()
+	if len(denials) != 5 || denials[0].Actor.Name != "bob" || denials[0].Permission != string(PermPaymentPay) ||
+		denials[0].AccountID != other.ID {
+		t.Errorf("wrong denials => %v", denials)
+	}
+}
+
+func TestAccess_returnsCopies_user(t *testing.T) {
+	var svc Service
+	admin := svc.As(types.Actor{Name: "root", Role: types.RoleAdmin})
+	registered, _ := admin.RegisterAccount("+992000000001")
+	registered.Balance = 1_000_00
+	admin.Deposit(registered.ID, 100_00)
+
+	payment, _ := admin.Pay(registered.ID, 10_00, "Cafe")
+	payment.Amount = 1_000_000
+	found, _ := admin.FindAccountByID(registered.ID)
+	found.Balance = 1_000_00
+	foundPayment, _ := admin.FindPaymentByID(payment.ID)
+	foundPayment.Amount = 1_000_000
+	repeated, _ := admin.Repeat(payment.ID)
+	repeated.Amount = 1_000_000
+	favorite, _ := admin.FavoritePayment(payment.ID, "Coffee")
+	favorite.Amount = 1_000_000
+	updated, _ := admin.UpdateFavorite(registered.ID, favorite.ID, "Tea", 10_00)
+	updated.Amount = 1_000_000
+	fromFavorite, _ := admin.PayFromFavorite(favorite.ID)
+	fromFavorite.Amount = 1_000_000
+	schedule, _ := admin.ScheduleFavorite(favorite.ID, types.ScheduleDaily, time.Now().Add(time.Hour))
+	schedule.Status = types.ScheduleDone
+	results, _ := admin.PayBatch([]types.BatchItem{{AccountID: registered.ID, Amount: 10_00, Category: "Cafe"}}, types.BatchBestEffort, 1)
+	results[0].Payment.Amount = 1_000_000
+
+	// refund of support uses stored amount, not the one changed by caller
+	svc.As(types.Actor{Name: "sam", Role: types.RoleSupport}).Reject(payment.ID)
+
+	account, _ := svc.FindAccountByID(registered.ID)
+	if account.Balance != 70_00 {
+		t.Errorf("stored balance was changed through Access => %v", account.Balance)
+	}
+	for _, v := range svc.payments {
+		if v.Amount != 10_00 {
+			t.Errorf("stored payment was changed through Access => %v", v)
+		}
+	}
+	if svc.favorites[0].Amount != 10_00 || svc.schedules[0].Status != types.ScheduleActive {
+		t.Errorf("stored favorite or schedule was changed through Access => %v %v", svc.favorites[0], svc.schedules[0])
+	}
+}
+
+func TestAccess_newMethods_user(t *testing.T) {
+	var svc Service
+	admin := svc.As(types.Actor{Name: "root", Role: types.RoleAdmin})
+	account, _ := admin.RegisterAccount("+992000000001")
+	other, _ := admin.RegisterAccount("+992000000002")
+	admin.Deposit(account.ID, 100_00)
+	admin.Deposit(other.ID, 100_00)
+	otherPayment, _ := admin.Pay(other.ID, 10_00, "Cafe")
+	otherFavorite, _ := admin.FavoritePayment(otherPayment.ID, "Lunch")
+
+	customer := svc.As(types.Actor{Name: "bob", Role: types.RoleCustomer, AccountID: account.ID})
+	denied := map[string]error{
+		"Confirm":          customer.Confirm(otherPayment.ID),
+		"RedeemPoints":     customer.RedeemPoints(other.ID, 1),
+		"DeleteFavorite":   customer.DeleteFavorite(other.ID, otherFavorite.ID),
+		"ChangePhone":      customer.ChangePhone(other.ID, "+992000000003"),
+		"SetPIN":           customer.SetPIN(other.ID, "1234"),
+		"HistoryToFiles":   customer.HistoryToFiles(nil, t.TempDir(), 10),
+		"ScheduleFavorite": second(customer.ScheduleFavorite(otherFavorite.ID, types.ScheduleDaily, time.Now())),
+		"UpdateFavorite":   second(customer.UpdateFavorite(other.ID, otherFavorite.ID, "Dinner", 1)),
+		"RequestMoney":     second(customer.RequestMoney(other.ID, "Cafe", []types.MoneyRequestShare{{Phone: account.Phone, Amount: 1}}, time.Hour)),
+	}
+	_, err := customer.PayBatch([]types.BatchItem{
+		{AccountID: account.ID, Amount: 1_00, Category: "Cafe"},
+		{AccountID: other.ID, Amount: 1_00, Category: "Cafe"},
+	}, types.BatchBestEffort, 1)
+	denied["PayBatch"] = err
+	for name, err := range denied {
+		if !errors.Is(err, ErrForbidden) {
+			t.Errorf("customer called %s of other account, error => %v", name, err)
+		}
+	}
+	if balance(&svc, account.ID) != 100_00 || balance(&svc, other.ID) != 90_00 {
+		t.Errorf("denied calls changed balances => %v %v", balance(&svc, account.ID), balance(&svc, other.ID))
+	}
+
+	if err := customer.SetPIN(account.ID, "1234"); err != nil {
+		t.Errorf("customer can not set own PIN, error => %v", err)
+	}
+}
+
+func second(_ interface{}, err error) error {
+	return err
+}
+
+//balance returns stored balance, Access returns only copies of accounts
+func balance(svc *Service, accountID int64) types.Money {
+
+	account, err := svc.FindAccountByID(accountID)
+	if err != nil {
+		return 0
+	}
+	return account.Balance
+}
+
+func TestAccess_denialsConcurrent_user(t *testing.T) {
+	var svc Service
+	customer := svc.As(types.Actor{Name: "bob", Role: types.RoleCustomer, AccountID: 1})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			customer.check(PermAccountDeposit, 1)
+			svc.AccessDenials()
+		}()
+	}
+	wg.Wait()
+	if len(svc.AccessDenials()) != 20 {
+		t.Errorf("wrong number of denials => %d", len(svc.AccessDenials()))
+	}
+}
+
+func TestAccess_wrapsAllMethods_user(t *testing.T) {
+	var svc Service
+	admin := svc.As(types.Actor{Name: "root", Role: types.RoleAdmin})
+	account, _ := admin.RegisterAccount("+992000000001")
+	other, _ := admin.RegisterAccount("+992000000002")
+	admin.Deposit(other.ID, 100_00)
+	payment, _ := admin.Pay(other.ID, 10_00, "Cafe")
+	favorite, _ := admin.FavoritePayment(payment.ID, "Lunch")
+	schedule, _ := admin.ScheduleFavorite(favorite.ID, types.ScheduleDaily, time.Now().Add(time.Hour))
+	request, _ := admin.RequestMoney(other.ID, "Cafe", []types.MoneyRequestShare{{Phone: account.Phone, Amount: 1}}, time.Hour)
+
+	customer := svc.As(types.Actor{Name: "bob", Role: types.RoleCustomer, AccountID: account.ID})
+	support := svc.As(types.Actor{Name: "sam", Role: types.RoleSupport})
+	denied := map[string]error{
+		"Accounts":              second(customer.Accounts()),
+		"FindAccountByPhone":    second(customer.FindAccountByPhone(other.Phone)),
+		"ExportToFile":          customer.ExportToFile(t.TempDir() + "/export.txt"),
+		"ImportFromFile":        support.ImportFromFile("export.txt"),
+		"AuditLog":              second(support.AuditLog()),
+		"AccountFavorites":      second(customer.AccountFavorites(other.ID)),
+		"Points":                second(customer.Points(other.ID)),
+		"AddRewardRule":         second(support.AddRewardRule("Cafe", types.RewardPoints, 10, 0)),
+		"PauseSchedule":         customer.PauseSchedule(schedule.ID),
+		"ResumeSchedule":        customer.ResumeSchedule(schedule.ID),
+		"SetScheduleRetry":      customer.SetScheduleRetry(schedule.ID, 1, time.Minute),
+		"DeclineMoneyRequest":   support.DeclineMoneyRequest(account.ID, request.ID),
+		"AcceptMoneyRequest":    second(support.AcceptMoneyRequest(account.ID, request.ID)),
+		"RegisterWebhook":       second(support.RegisterWebhook("http://example.com", "secret", 0, "")),
+		"RemoveWebhook":         support.RemoveWebhook("x"),
+		"ExpireMoneyRequests":   support.ExpireMoneyRequests(),
+		"FilterPaymentsByFn":    second(customer.FilterPaymentsByFn(func(types.Payment) bool { return true }, 1)),
+		"IncomingMoneyRequests": second(customer.IncomingMoneyRequests(other.ID)),
+	}
+	for name, err := range denied {
+		if !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s was not checked, error => %v", name, err)
+		}
+	}
+	if found, _ := svc.FindScheduleByID(schedule.ID); found.Status != types.ScheduleActive {
+		t.Errorf("denied call changed schedule => %v", found)
+	}
+
+	auditor := svc.As(types.Actor{Name: "ann", Role: types.RoleAuditor})
+	if records, err := auditor.AuditLog(); err != nil || len(records) == 0 {
+		t.Errorf("auditor can not read audit log => %d, error => %v", len(records), err)
+	}
+	if err := svc.As(types.Actor{Name: "sue", Role: types.RoleCustomer, AccountID: other.ID}).PauseSchedule(schedule.ID); err != nil {
+		t.Errorf("owner can not pause schedule, error => %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package wallet
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrMoneyRequestNotFound -- money request not found
+var ErrMoneyRequestNotFound = errors.New("money request not found")
+
+//ErrMoneyRequestInvalid -- money request is invalid
+var ErrMoneyRequestInvalid = errors.New("money request is invalid")
+
+//ErrMoneyRequestExpired -- money request expired
+var ErrMoneyRequestExpired = errors.New("money request expired")
+
+//ErrMoneyRequestNotPending -- money request share already settled
+var ErrMoneyRequestNotPending = errors.New("money request is not pending")
+
+//ErrPaymentNotRejectable -- payment of accepted money request was already credited to requester
+var ErrPaymentNotRejectable = errors.New("payment can not be rejected")
+
+//FindAccountByPhone method
+func (s *Service) FindAccountByPhone(phone types.Phone) (*types.Account, error) {
+
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range s.accounts {
+		if account.Phone == phone {
+			return account, nil
+		}
+	}
+	return nil, &Error{Err: ErrAccountNotFound, Phone: phone}
+}
+
+//RequestMoney asks accounts with given phones to pay their shares to account
+func (s *Service) RequestMoney(accountID int64, category types.PaymentCategory, shares []types.MoneyRequestShare, ttl time.Duration) (*types.MoneyRequest, error) {
+
+	if len(shares) == 0 || ttl <= 0 {
+		return nil, ErrMoneyRequestInvalid
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOpen(account)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[types.Phone]bool)
+	for _, v := range shares {
+		if v.Amount <= 0 {
+			return nil, &Error{Err: ErrAmountMustBePositive, Phone: v.Phone, Amount: v.Amount}
+		}
+		recipient, err := s.FindAccountByPhone(v.Phone)
+		if err != nil {
+			return nil, err
+		}
+		if recipient.ID == accountID || seen[recipient.Phone] {
+			return nil, ErrMoneyRequestInvalid
+		}
+		seen[recipient.Phone] = true
+	}
+
+	now := s.currentTime()
+	request := &types.MoneyRequest{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Category:  category,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	for _, v := range shares {
+		phone, _ := NormalizePhone(v.Phone)
+		request.Shares = append(request.Shares, types.MoneyRequestShare{
+			Phone:  phone,
+			Amount: v.Amount,
+			Status: types.MoneyRequestPending,
+		})
+	}
+	s.moneyRequests = append(s.moneyRequests, request)
+
+	return copyMoneyRequest(request), nil
+}
+
+//FindMoneyRequest returns request with settlement of each share, only to requester
+func (s *Service) FindMoneyRequest(accountID int64, requestID string) (*types.MoneyRequest, error) {
+
+	request, err := s.findMoneyRequest(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.AccountID != accountID {
+		return nil, ErrMoneyRequestNotFound
+	}
+	s.expireMoneyRequest(request)
+	return copyMoneyRequest(request), nil
+}
+
+//IncomingMoneyRequests returns pending requests waiting for account
+func (s *Service) IncomingMoneyRequests(accountID int64) ([]types.MoneyRequest, error) {
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []types.MoneyRequest
+	for _, v := range s.moneyRequests {
+		s.expireMoneyRequest(v)
+		share := findShare(v, account.Phone)
+		if share != nil && share.Status == types.MoneyRequestPending {
+			requests = append(requests, *copyMoneyRequest(v))
+		}
+	}
+	return requests, nil
+}
+
+//AcceptMoneyRequest transfers share of account to requester
+func (s *Service) AcceptMoneyRequest(accountID int64, requestID string) (*types.Payment, error) {
+
+	request, share, err := s.pendingShare(accountID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	requester, err := s.FindAccountByID(request.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOpen(requester)
+	if err != nil {
+		return nil, err
+	}
+
+	before := s.accountSnapshot(accountID)
+	requesterBefore := s.accountSnapshot(requester.ID)
+	payment, err := s.newPayment(accountID, share.Amount, request.Category)
+	if err != nil {
+		return nil, err
+	}
+	payment.Status = types.PaymentStatusOk
+	// requester is credited before payment is audited and published, so subscribers see both sides
+	requester.Balance += share.Amount
+	share.Status = types.MoneyRequestAccepted
+	share.PaymentID = payment.ID
+	s.addPayment(payment, before)
+	s.audit("money_request.accept", requesterBefore, requester)
+	s.publish(types.Event{Type: types.EventDeposited, AccountID: requester.ID, Amount: share.Amount})
+
+	return payment, nil
+}
+
+//DeclineMoneyRequest method
+func (s *Service) DeclineMoneyRequest(accountID int64, requestID string) error {
+
+	_, share, err := s.pendingShare(accountID, requestID)
+	if err != nil {
+		return err
+	}
+
+	share.Status = types.MoneyRequestDeclined
+	return nil
+}
+
+//ExpireMoneyRequests marks overdue pending shares as expired
+func (s *Service) ExpireMoneyRequests() {
+
+	for _, v := range s.moneyRequests {
+		s.expireMoneyRequest(v)
+	}
+}
+
+//paysMoneyRequest reports whether payment is share of accepted money request
+func (s *Service) paysMoneyRequest(paymentID string) bool {
+
+	for _, request := range s.moneyRequests {
+		for _, share := range request.Shares {
+			if share.PaymentID == paymentID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Service) pendingShare(accountID int64, requestID string) (*types.MoneyRequest, *types.MoneyRequestShare, error) {
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	request, err := s.findMoneyRequest(requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	share := findShare(request, account.Phone)
+	if share == nil {
+		return nil, nil, ErrMoneyRequestNotFound
+	}
+
+	s.expireMoneyRequest(request)
+	if share.Status == types.MoneyRequestExpired {
+		return nil, nil, ErrMoneyRequestExpired
+	}
+	if share.Status != types.MoneyRequestPending {
+		return nil, nil, ErrMoneyRequestNotPending
+	}
+	return request, share, nil
+}
+
+func (s *Service) findMoneyRequest(requestID string) (*types.MoneyRequest, error) {
+
+	for _, v := range s.moneyRequests {
+		if v.ID == requestID {
+			return v, nil
+		}
+	}
+	return nil, ErrMoneyRequestNotFound
+}
+
+func (s *Service) expireMoneyRequest(request *types.MoneyRequest) {
+
+	if s.currentTime().Before(request.ExpiresAt) {
+		return
+	}
+	for i := range request.Shares {
+		if request.Shares[i].Status == types.MoneyRequestPending {
+			request.Shares[i].Status = types.MoneyRequestExpired
+		}
+	}
+}
+
+//copyMoneyRequest returns request with its own shares, so caller can not change stored one
+func copyMoneyRequest(request *types.MoneyRequest) *types.MoneyRequest {
+
+	copied := *request
+	copied.Shares = append([]types.MoneyRequestShare(nil), request.Shares...)
+	return &copied
+}
+
+func findShare(request *types.MoneyRequest, phone types.Phone) *types.MoneyRequestShare {
+
+	for i := range request.Shares {
+		if request.Shares[i].Phone == phone {
+			return &request.Shares[i]
+		}
+	}
+	return nil
+}
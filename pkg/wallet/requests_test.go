@@ -0,0 +1,160 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestService_RequestMoney_split_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	payer, _ := svc.RegisterAccount("+992000000001")
+	friend, _ := svc.RegisterAccount("+992000000002")
+	other, _ := svc.RegisterAccount("+992000000003")
+	svc.Deposit(friend.ID, 50_00)
+
+	request, err := svc.RequestMoney(payer.ID, "Restaurant", []types.MoneyRequestShare{
+		{Phone: friend.Phone, Amount: 30_00},
+		{Phone: other.Phone, Amount: 30_00},
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("method RequestMoney returned not nil error, error => %v", err)
+	}
+
+	incoming, _ := svc.IncomingMoneyRequests(friend.ID)
+	if len(incoming) != 1 {
+		t.Errorf("wrong incoming requests => %v", incoming)
+	}
+
+	payment, err := svc.AcceptMoneyRequest(friend.ID, request.ID)
+	if err != nil {
+		t.Fatalf("method AcceptMoneyRequest returned not nil error, error => %v", err)
+	}
+	if payer.Balance != 30_00 || friend.Balance != 20_00 {
+		t.Errorf("wrong balances => %v %v", payer.Balance, friend.Balance)
+	}
+
+	err = svc.DeclineMoneyRequest(friend.ID, request.ID)
+	if !errors.Is(err, ErrMoneyRequestNotPending) {
+		t.Errorf("method DeclineMoneyRequest returned wrong error, error => %v", err)
+	}
+
+	err = svc.DeclineMoneyRequest(other.ID, request.ID)
+	if err != nil {
+		t.Errorf("method DeclineMoneyRequest returned not nil error, error => %v", err)
+	}
+
+	got, err := svc.FindMoneyRequest(payer.ID, request.ID)
+	if err != nil {
+		t.Fatalf("method FindMoneyRequest returned not nil error, error => %v", err)
+	}
+	if got.Shares[0].Status != types.MoneyRequestAccepted || got.Shares[0].PaymentID != payment.ID ||
+		got.Shares[1].Status != types.MoneyRequestDeclined {
+		t.Errorf("wrong shares => %v", got.Shares)
+	}
+
+	_, err = svc.FindMoneyRequest(friend.ID, request.ID)
+	if !errors.Is(err, ErrMoneyRequestNotFound) {
+		t.Errorf("method FindMoneyRequest returned wrong error, error => %v", err)
+	}
+}
+
+func TestService_AcceptMoneyRequest_expired_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	payer, _ := svc.RegisterAccount("+992000000001")
+	friend, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(friend.ID, 50_00)
+
+	request, _ := svc.RequestMoney(payer.ID, "Taxi", []types.MoneyRequestShare{
+		{Phone: friend.Phone, Amount: 10_00},
+	}, time.Hour)
+
+	clock.now = clock.now.Add(time.Hour)
+	_, err := svc.AcceptMoneyRequest(friend.ID, request.ID)
+	if !errors.Is(err, ErrMoneyRequestExpired) {
+		t.Errorf("method AcceptMoneyRequest returned wrong error, error => %v", err)
+	}
+	got, _ := svc.FindMoneyRequest(payer.ID, request.ID)
+	if got.Shares[0].Status != types.MoneyRequestExpired || friend.Balance != 50_00 {
+		t.Errorf("share not expired => %v", got.Shares[0])
+	}
+}
+
+func TestService_AcceptMoneyRequest_auditsCredit_user(t *testing.T) {
+	var svc Service
+	payer, _ := svc.RegisterAccount("+992000000001")
+	friend, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(friend.ID, 50_00)
+
+	request, _ := svc.RequestMoney(payer.ID, "Taxi", []types.MoneyRequestShare{
+		{Phone: friend.Phone, Amount: 10_00},
+	}, time.Hour)
+	var requesterBalance types.Money
+	svc.Subscribe(func(event types.Event) {
+		if event.Type == types.EventPaymentCreated {
+			requesterBalance = payer.Balance
+		}
+	}, DeliverSync)
+
+	_, err := svc.AcceptMoneyRequest(friend.ID, request.ID)
+	if err != nil {
+		t.Fatalf("method AcceptMoneyRequest returned not nil error, error => %v", err)
+	}
+	if requesterBalance != 10_00 {
+		t.Errorf("requester was not credited before payment event, balance => %v", requesterBalance)
+	}
+	log := svc.AuditLog()
+	if last := log[len(log)-1]; last.Action != "money_request.accept" {
+		t.Errorf("credit of requester was not audited => %v", last)
+	}
+}
+
+func TestService_AcceptMoneyRequest_reject_user(t *testing.T) {
+	var svc Service
+	payer, _ := svc.RegisterAccount("+992000000001")
+	friend, _ := svc.RegisterAccount("+992000000002")
+	svc.Deposit(payer.ID, 5_00)
+	svc.Deposit(friend.ID, 5_00)
+
+	request, _ := svc.RequestMoney(payer.ID, "Taxi", []types.MoneyRequestShare{
+		{Phone: friend.Phone, Amount: 5_00},
+	}, time.Hour)
+	payment, err := svc.AcceptMoneyRequest(friend.ID, request.ID)
+	if err != nil {
+		t.Fatalf("method AcceptMoneyRequest returned not nil error, error => %v", err)
+	}
+
+	err = svc.Reject(payment.ID)
+	if !errors.Is(err, ErrPaymentNotRejectable) {
+		t.Errorf("method Reject returned wrong error, error => %v", err)
+	}
+	if payer.Balance+friend.Balance != 10_00 || friend.Balance != 0 {
+		t.Errorf("reject changed balances => %v %v", payer.Balance, friend.Balance)
+	}
+}
+
+func TestService_FindMoneyRequest_returnsCopy_user(t *testing.T) {
+	var svc Service
+	payer, _ := svc.RegisterAccount("+992000000001")
+	friend, _ := svc.RegisterAccount("+992000000002")
+
+	request, _ := svc.RequestMoney(payer.ID, "Taxi", []types.MoneyRequestShare{
+		{Phone: friend.Phone, Amount: 10_00},
+	}, time.Hour)
+	request.Shares[0].Status = types.MoneyRequestAccepted
+
+	got, _ := svc.FindMoneyRequest(payer.ID, request.ID)
+	got.Shares[0].Amount = 1
+	got, _ = svc.FindMoneyRequest(payer.ID, request.ID)
+	if got.Shares[0].Status != types.MoneyRequestPending || got.Shares[0].Amount != 10_00 {
+		t.Errorf("stored request was changed through returned one => %v", got.Shares[0])
+	}
+}
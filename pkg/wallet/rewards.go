@@ -0,0 +1,207 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrRewardRuleInvalid -- reward rule is invalid
+var ErrRewardRuleInvalid = errors.New("reward rule is invalid")
+
+//ErrPaymentNotInProgress -- payment is not in progress
+var ErrPaymentNotInProgress = errors.New("payment is not in progress")
+
+//ErrNotEnoughPoints -- account not enough points
+var ErrNotEnoughPoints = errors.New("account not enough points")
+
+//AddRewardRule adds cashback or points rule for payment category
+func (s *Service) AddRewardRule(category types.PaymentCategory, kind types.RewardKind, percent int64, monthlyCap types.Money) (*types.RewardRule, error) {
+
+	if percent <= 0 || percent > 100 || monthlyCap < 0 {
+		return nil, ErrRewardRuleInvalid
+	}
+	if kind != types.RewardCashback && kind != types.RewardPoints {
+		return nil, ErrRewardRuleInvalid
+	}
+
+	rule := &types.RewardRule{
+		ID:         uuid.New().String(),
+		Category:   category,
+		Kind:       kind,
+		Percent:    percent,
+		MonthlyCap: monthlyCap,
+	}
+	s.rewardRules = append(s.rewardRules, rule)
+
+	return rule, nil
+}
+
+//Confirm method sets payment status to OK and accrues rewards
+func (s *Service) Confirm(paymentID string) error {
+
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return err
+	}
+	if payment.Status != types.PaymentStatusInProgress {
+		return &Error{Err: ErrPaymentNotInProgress, AccountID: payment.AccountID, PaymentID: payment.ID}
+	}
+
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return err
+	}
+
+	before := paymentChange{Account: *account, Payment: *payment}
+	payment.Status = types.PaymentStatusOk
+	if checkOpen(account) == nil {
+		s.accrueRewards(account, payment)
+	}
+	s.audit("payment.confirm", before, paymentChange{Account: account, Payment: payment})
+	s.publish(types.Event{Type: types.EventPaymentConfirmed, AccountID: account.ID, Amount: payment.Amount, Payment: payment, From: types.PaymentStatusInProgress})
+
+	return nil
+}
+
+//Points returns points balance of account
+func (s *Service) Points(accountID int64) (types.Money, error) {
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return 0, err
+	}
+	return s.points[accountID], nil
+}
+
+//RedeemPoints moves points into account balance one to one
+func (s *Service) RedeemPoints(accountID int64, points types.Money) error {
+
+	if points <= 0 {
+		return &Error{Err: ErrAmountMustBePositive, AccountID: accountID, Amount: points}
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	err = checkActive(account)
+	if err != nil {
+		return err
+	}
+	if s.points[accountID] < points {
+		return ErrNotEnoughPoints
+	}
+
+	before := *account
+	s.points[accountID] -= points
+	account.Balance += points
+	s.audit("points.redeem", before, account)
+	s.publish(types.Event{Type: types.EventPointsRedeemed, AccountID: accountID, Amount: points})
+
+	return nil
+}
+
+//AccountRewards returns rewards accrued for account
+func (s *Service) AccountRewards(accountID int64) ([]types.Reward, error) {
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rewards []types.Reward
+	for _, v := range s.rewards {
+		if v.AccountID == accountID {
+			rewards = append(rewards, *v)
+		}
+	}
+	return rewards, nil
+}
+
+func (s *Service) accrueRewards(account *types.Account, payment *types.Payment) {
+
+	period := s.currentTime().Format("2006-01")
+	for _, rule := range s.rewardRules {
+		if rule.Category != payment.Category {
+			continue
+		}
+
+		amount := payment.Amount * types.Money(rule.Percent) / 100
+		if rule.MonthlyCap > 0 {
+			left := rule.MonthlyCap - s.accrued(rule.ID, account.ID, period)
+			if amount > left {
+				amount = left
+			}
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		reward := &types.Reward{
+			ID:        uuid.New().String(),
+			RuleID:    rule.ID,
+			PaymentID: payment.ID,
+			AccountID: account.ID,
+			Kind:      rule.Kind,
+			Amount:    amount,
+			Period:    period,
+		}
+		s.rewards = append(s.rewards, reward)
+		s.applyReward(account, reward, amount)
+	}
+}
+
+func (s *Service) reverseRewards(payment *types.Payment) {
+
+	for _, v := range s.rewards {
+		if v.PaymentID != payment.ID || v.Reversed {
+			continue
+		}
+		account, err := s.FindAccountByID(v.AccountID)
+		if err != nil {
+			continue
+		}
+		v.Reversed = true
+		s.applyReward(account, v, -reversible(v, account.Balance, s.points[account.ID]))
+	}
+}
+
+//reversible returns how much of reward can be taken back, spent cashback and redeemed points are not
+func reversible(reward *types.Reward, balance types.Money, points types.Money) types.Money {
+
+	available := points
+	if reward.Kind == types.RewardCashback {
+		available = balance
+	}
+	if available < 0 {
+		return 0
+	}
+	if reward.Amount > available {
+		return available
+	}
+	return reward.Amount
+}
+
+func (s *Service) applyReward(account *types.Account, reward *types.Reward, amount types.Money) {
+
+	if reward.Kind == types.RewardCashback {
+		account.Balance += amount
+		return
+	}
+	if s.points == nil {
+		s.points = make(map[int64]types.Money)
+	}
+	s.points[account.ID] += amount
+}
+
+func (s *Service) accrued(ruleID string, accountID int64, period string) types.Money {
+
+	sum := types.Money(0)
+	for _, v := range s.rewards {
+		if v.RuleID == ruleID && v.AccountID == accountID && v.Period == period && !v.Reversed {
+			sum += v.Amount
+		}
+	}
+	return sum
+}
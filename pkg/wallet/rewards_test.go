@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestService_Confirm_cashback_user(t *testing.T) {
+	var svc Service
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatalf("method RegisterAccount returned not nil error, error => %v", err)
+	}
+	svc.Deposit(account.ID, 1000_00)
+
+	_, err = svc.AddRewardRule("food", types.RewardCashback, 5, 100)
+	if err != nil {
+		t.Fatalf("method AddRewardRule returned not nil error, error => %v", err)
+	}
+
+	payment, _ := svc.Pay(account.ID, 1000, "food")
+	err = svc.Confirm(payment.ID)
+	if err != nil {
+		t.Errorf("method Confirm returned not nil error, error => %v", err)
+	}
+	if account.Balance != 1000_00-1000+50 {
+		t.Errorf("wrong balance after cashback, balance => %v", account.Balance)
+	}
+
+	payment, _ = svc.Pay(account.ID, 10000, "food")
+	svc.Confirm(payment.ID)
+	if account.Balance != 1000_00-11000+100 {
+		t.Errorf("monthly cap not applied, balance => %v", account.Balance)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Errorf("method Reject returned not nil error, error => %v", err)
+	}
+	if account.Balance != 1000_00-1000+50 {
+		t.Errorf("cashback not reversed, balance => %v", account.Balance)
+	}
+}
+
+func TestService_RedeemPoints_success_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 1000)
+	svc.AddRewardRule("food", types.RewardPoints, 10, 0)
+
+	payment, _ := svc.Pay(account.ID, 1000, "food")
+	svc.Confirm(payment.ID)
+
+	points, err := svc.Points(account.ID)
+	if err != nil || points != 100 {
+		t.Fatalf("wrong points, points => %v, error => %v", points, err)
+	}
+
+	err = svc.RedeemPoints(account.ID, 200)
+	if !errors.Is(err, ErrNotEnoughPoints) {
+		t.Errorf("method RedeemPoints returned wrong error, error => %v", err)
+	}
+
+	err = svc.RedeemPoints(account.ID, 100)
+	if err != nil {
+		t.Errorf("method RedeemPoints returned not nil error, error => %v", err)
+	}
+	if account.Balance != 100 {
+		t.Errorf("points not redeemed, balance => %v", account.Balance)
+	}
+}
+
+func TestService_Confirm_fail_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 1000)
+	payment, _ := svc.Pay(account.ID, 100, "food")
+	svc.Reject(payment.ID)
+
+	err := svc.Confirm(payment.ID)
+	if !errors.Is(err, ErrPaymentNotInProgress) {
+		t.Errorf("method Confirm returned wrong error, error => %v", err)
+	}
+}
+
+func TestService_Reject_twice_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100)
+	payment, _ := svc.Pay(account.ID, 100, "food")
+
+	err := svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatalf("method Reject returned not nil error, error => %v", err)
+	}
+	rejected := 0
+	svc.Subscribe(func(event types.Event) { rejected++ }, DeliverSync)
+
+	err = svc.Reject(payment.ID)
+	if !errors.Is(err, ErrPaymentNotInProgress) {
+		t.Errorf("second Reject returned wrong error, error => %v", err)
+	}
+	if account.Balance != 100 || rejected != 0 {
+		t.Errorf("second Reject refunded again, balance => %v, events => %d", account.Balance, rejected)
+	}
+}
+
+func TestService_Reject_afterRedeem_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 1000)
+	svc.AddRewardRule("food", types.RewardPoints, 10, 0)
+	payment, _ := svc.Pay(account.ID, 1000, "food")
+	svc.Confirm(payment.ID)
+	svc.RedeemPoints(account.ID, 60)
+
+	err := svc.Reject(payment.ID)
+	if err != nil {
+		t.Fatalf("method Reject returned not nil error, error => %v", err)
+	}
+	points, _ := svc.Points(account.ID)
+	if points != 0 || account.Balance != 1060 {
+		t.Errorf("reversal took more than left, points => %v, balance => %v", points, account.Balance)
+	}
+}
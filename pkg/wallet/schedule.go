@@ -0,0 +1,269 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrScheduleNotFound -- schedule not found
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+//ErrScheduleInvalid -- schedule is invalid
+var ErrScheduleInvalid = errors.New("schedule is invalid")
+
+//ErrScheduleNotActive -- schedule is not active
+var ErrScheduleNotActive = errors.New("schedule is not active")
+
+//Clock gives current time and tickers, can be replaced in tests
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+//Ticker delivers ticks of Clock
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+//SetClock replaces clock used by service
+func (s *Service) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+func (s *Service) getClock() Clock {
+	if s.clock == nil {
+		return systemClock{}
+	}
+	return s.clock
+}
+
+func (s *Service) currentTime() time.Time {
+	return s.getClock().Now()
+}
+
+//ScheduleFavorite attaches schedule to favorite, monthly schedules run on day of start
+func (s *Service) ScheduleFavorite(favoriteID string, kind types.ScheduleKind, start time.Time) (*types.Schedule, error) {
+
+	_, err := s.findFavorite(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case types.ScheduleOnce, types.ScheduleDaily, types.ScheduleWeekly, types.ScheduleMonthly:
+	default:
+		return nil, ErrScheduleInvalid
+	}
+
+	schedule := &types.Schedule{
+		ID:         uuid.New().String(),
+		FavoriteID: favoriteID,
+		Kind:       kind,
+		Day:        start.Day(),
+		RunAt:      start,
+		NextRun:    start,
+		Status:     types.ScheduleActive,
+	}
+	s.schedules = append(s.schedules, schedule)
+
+	return schedule, nil
+}
+
+//FindScheduleByID method
+func (s *Service) FindScheduleByID(scheduleID string) (*types.Schedule, error) {
+
+	for _, schedule := range s.schedules {
+		if schedule.ID == scheduleID {
+			return schedule, nil
+		}
+	}
+	return nil, ErrScheduleNotFound
+}
+
+//SetScheduleRetry sets how many times and how often payment is retried on not enough balance
+func (s *Service) SetScheduleRetry(scheduleID string, maxRetries int, interval time.Duration) error {
+
+	if maxRetries < 0 || (maxRetries > 0 && interval <= 0) {
+		return ErrScheduleInvalid
+	}
+	schedule, err := s.FindScheduleByID(scheduleID)
+	if err != nil {
+		return err
+	}
+
+	schedule.MaxRetries = maxRetries
+	schedule.RetryInterval = interval
+	return nil
+}
+
+//PauseSchedule method
+func (s *Service) PauseSchedule(scheduleID string) error {
+
+	schedule, err := s.FindScheduleByID(scheduleID)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != types.ScheduleActive {
+		return ErrScheduleNotActive
+	}
+
+	schedule.Status = types.SchedulePaused
+	return nil
+}
+
+//ResumeSchedule method, runs missed while paused are skipped
+func (s *Service) ResumeSchedule(scheduleID string) error {
+
+	schedule, err := s.FindScheduleByID(scheduleID)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != types.SchedulePaused {
+		return ErrScheduleNotActive
+	}
+
+	schedule.Status = types.ScheduleActive
+	schedule.Retries = 0
+	if schedule.Kind == types.ScheduleOnce {
+		return nil
+	}
+
+	now := s.currentTime()
+	for schedule.RunAt.Before(now) {
+		schedule.RunAt = nextRun(schedule)
+	}
+	schedule.NextRun = schedule.RunAt
+	return nil
+}
+
+//ScheduleHistory returns executions of schedule
+func (s *Service) ScheduleHistory(scheduleID string) ([]types.ScheduleExecution, error) {
+
+	_, err := s.FindScheduleByID(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var executions []types.ScheduleExecution
+	for _, v := range s.executions {
+		if v.ScheduleID == scheduleID {
+			executions = append(executions, *v)
+		}
+	}
+	return executions, nil
+}
+
+//RunSchedules executes all schedules that are due at current time
+func (s *Service) RunSchedules() []types.ScheduleExecution {
+
+	executions, _ := s.runSchedules(newTracker(context.Background(), "run schedules", 0))
+	return executions
+}
+
+func (s *Service) runSchedules(t *tracker) ([]types.ScheduleExecution, error) {
+
+	now := s.currentTime()
+	var executions []types.ScheduleExecution
+	t.total = len(s.schedules)
+	for _, schedule := range s.schedules {
+		if err := t.check(); err != nil {
+			return executions, err
+		}
+		t.add(1)
+		if schedule.Status != types.ScheduleActive || schedule.NextRun.After(now) {
+			continue
+		}
+
+		execution := &types.ScheduleExecution{
+			ScheduleID: schedule.ID,
+			At:         now,
+			Attempt:    schedule.Retries + 1,
+		}
+		payment, err := s.PayFromFavorite(schedule.FavoriteID)
+		if err != nil {
+			execution.Error = err.Error()
+		} else {
+			execution.PaymentID = payment.ID
+		}
+		s.executions = append(s.executions, execution)
+		executions = append(executions, *execution)
+
+		if errors.Is(err, ErrNotEnoughtBalance) && schedule.Retries < schedule.MaxRetries {
+			schedule.Retries++
+			schedule.NextRun = now.Add(schedule.RetryInterval)
+			continue
+		}
+
+		schedule.Retries = 0
+		if schedule.Kind == types.ScheduleOnce {
+			schedule.Status = types.ScheduleDone
+			continue
+		}
+		schedule.RunAt = nextRun(schedule)
+		schedule.NextRun = schedule.RunAt
+	}
+	return executions, nil
+}
+
+//StartScheduler runs due schedules on every tick until returned stop is called.
+//Every tick holds Locker.
+func (s *Service) StartScheduler(interval time.Duration) (stop func()) {
+	return s.StartSchedulerContext(context.Background(), interval)
+}
+
+//StartSchedulerContext is StartScheduler which also stops when ctx is done
+func (s *Service) StartSchedulerContext(ctx context.Context, interval time.Duration) (stop func()) {
+
+	return s.startLoop(ctx, interval, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.RunSchedulesContext(ctx)
+	})
+}
+
+func nextRun(schedule *types.Schedule) time.Time {
+
+	switch schedule.Kind {
+	case types.ScheduleDaily:
+		return schedule.RunAt.AddDate(0, 0, 1)
+	case types.ScheduleWeekly:
+		return schedule.RunAt.AddDate(0, 0, 7)
+	case types.ScheduleMonthly:
+		t := schedule.RunAt
+		first := time.Date(t.Year(), t.Month()+1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		day := schedule.Day
+		last := first.AddDate(0, 1, -1).Day()
+		if day > last {
+			day = last
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+	return schedule.RunAt
+}
@@ -0,0 +1,140 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now    time.Time
+	ticker *fakeTicker
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.ticker = &fakeTicker{ch: make(chan time.Time)}
+	return c.ticker
+}
+
+type fakeTicker struct {
+	ch chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTicker) Stop() {}
+
+func TestService_RunSchedules_monthly_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 31, 10, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	favorite, _ := svc.FavoritePayment(payment.ID, "Rent")
+
+	schedule, err := svc.ScheduleFavorite(favorite.ID, "MONTHLY", clock.now)
+	if err != nil {
+		t.Fatalf("method ScheduleFavorite returned not nil error, error => %v", err)
+	}
+
+	executions := svc.RunSchedules()
+	if len(executions) != 1 || executions[0].Error != "" {
+		t.Errorf("wrong executions => %v", executions)
+	}
+	want := time.Date(2020, 2, 29, 10, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("wrong next run, want => %v got => %v", want, schedule.NextRun)
+	}
+
+	if len(svc.RunSchedules()) != 0 {
+		t.Errorf("schedule executed before next run")
+	}
+
+	clock.now = want
+	svc.RunSchedules()
+	want = time.Date(2020, 3, 31, 10, 0, 0, 0, time.UTC)
+	if !schedule.NextRun.Equal(want) {
+		t.Errorf("wrong next run, want => %v got => %v", want, schedule.NextRun)
+	}
+	if account.Balance != 70_00 {
+		t.Errorf("wrong balance => %v", account.Balance)
+	}
+}
+
+func TestService_RunSchedules_retry_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 10_00)
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	favorite, _ := svc.FavoritePayment(payment.ID, "Phone")
+
+	schedule, _ := svc.ScheduleFavorite(favorite.ID, "DAILY", clock.now)
+	svc.SetScheduleRetry(schedule.ID, 1, time.Hour)
+
+	svc.RunSchedules()
+	if schedule.Retries != 1 || !schedule.NextRun.Equal(clock.now.Add(time.Hour)) {
+		t.Errorf("retry not scheduled => %v", schedule)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	svc.Deposit(account.ID, 10_00)
+	svc.RunSchedules()
+
+	history, err := svc.ScheduleHistory(schedule.ID)
+	if err != nil {
+		t.Fatalf("method ScheduleHistory returned not nil error, error => %v", err)
+	}
+	if len(history) != 2 || !strings.HasPrefix(history[0].Error, ErrNotEnoughtBalance.Error()) || history[1].PaymentID == "" {
+		t.Errorf("wrong history => %v", history)
+	}
+	if !schedule.NextRun.Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong next run => %v", schedule.NextRun)
+	}
+}
+
+func TestService_PauseSchedule_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 1_00, "Cafe")
+	favorite, _ := svc.FavoritePayment(payment.ID, "Coffee")
+	schedule, _ := svc.ScheduleFavorite(favorite.ID, "WEEKLY", clock.now)
+
+	svc.PauseSchedule(schedule.ID)
+	if len(svc.RunSchedules()) != 0 {
+		t.Errorf("paused schedule executed")
+	}
+
+	clock.now = clock.now.AddDate(0, 0, 10)
+	err := svc.ResumeSchedule(schedule.ID)
+	if err != nil {
+		t.Fatalf("method ResumeSchedule returned not nil error, error => %v", err)
+	}
+	if !schedule.NextRun.Equal(time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong next run after resume => %v", schedule.NextRun)
+	}
+
+	stop := svc.StartScheduler(time.Minute)
+	clock.now = schedule.NextRun
+	clock.ticker.ch <- clock.now
+	stop()
+
+	history, _ := svc.ScheduleHistory(schedule.ID)
+	if len(history) != 1 {
+		t.Errorf("scheduler did not run schedule, history => %v", history)
+	}
+}
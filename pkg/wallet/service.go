@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/khushbakhtmahkamov/wallet/pkg/types"
@@ -24,37 +26,125 @@ var ErrAmountMustBePositive = errors.New("amount must be greater than zero")
 //ErrAccountNotFound -- account not found
 var ErrAccountNotFound = errors.New("account not found")
 
-//ErrNotEnoughtBalance -- account not found
+//ErrNotEnoughtBalance -- account has not enough balance for payment
 var ErrNotEnoughtBalance = errors.New("account not enought balance")
 
-//ErrPaymentNotFound -- account not found
+//ErrPaymentNotFound -- payment not found
 var ErrPaymentNotFound = errors.New("payment not found")
 
 //ErrFavoriteNotFound -- favorite not found
 var ErrFavoriteNotFound = errors.New("favorite not found")
 
+//ErrDumpInvalid -- line of dump file has wrong number of fields or unknown status
+var ErrDumpInvalid = errors.New("dump line is invalid")
+
 //Service model
 type Service struct {
 	nextAccountID int64
 	accounts      []*types.Account
 	payments      []*types.Payment
 	favorites     []*types.Favorite
+	rewardRules   []*types.RewardRule
+	rewards       []*types.Reward
+	points        map[int64]types.Money
+	schedules     []*types.Schedule
+	executions    []*types.ScheduleExecution
+	moneyRequests []*types.MoneyRequest
+	phoneChanges  []*types.PhoneChange
+	codeSender    CodeSender
+	codes         map[string]*pendingCode
+	codeFailures  map[string]*codeFailures
+	codeTTL       time.Duration
+	codeAttempts  int
+	unverified    map[int64]bool
+	payThreshold  types.Money
+	credentials   map[int64]*credential
+	sessions      map[string]*session
+	sessionTTL    time.Duration
+	denials       []*types.AccessDenial
+	denialsMu     sync.Mutex
+	auditLog      []types.AuditRecord
+	bus           *eventBus
+	eventSeq      int64
+	outbox        []*types.OutboxEntry
+	outboxLimit   int
+	outboxSpill   string
+	outboxSpilled int
+	webhooks      []*types.Webhook
+	webhookKeys   map[string]string
+	deliveries    []*types.WebhookDelivery
+	deadLetters   []*types.WebhookDelivery
+	actor         string
+	clock         Clock
+	lock          sync.Mutex
+}
+
+//Locker returns lock of service, everyone who uses service from several goroutines must hold it.
+//Background loops like StartScheduler hold it on every tick.
+func (s *Service) Locker() sync.Locker {
+	return &s.lock
+}
+
+//startLoop calls tick on every tick of clock until returned stop is called or ctx is done.
+//tick takes Locker itself, so service can be shared with servers which hold it too.
+func (s *Service) startLoop(ctx context.Context, interval time.Duration, tick func()) (stop func()) {
+
+	ticker := s.getClock().NewTicker(interval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C():
+				tick()
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-finished
+	}
 }
 
 //RegisterAccount meth
 func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
-	for _, account := range s.accounts {
-		if account.Phone == phone {
-			return nil, ErrPhoneRegistered
+	normalized, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.FindAccountByPhone(normalized); err == nil {
+		return nil, ErrPhoneRegistered
+	}
+	if s.codeSender != nil {
+		err = s.issueCode(verifyKey(s.nextAccountID+1), normalized)
+		if err != nil {
+			return nil, err
 		}
 	}
 	s.nextAccountID++
 	account := &types.Account{
 		ID:      s.nextAccountID,
-		Phone:   phone,
+		Phone:   normalized,
 		Balance: 0,
+		Status:  types.AccountActive,
 	}
 	s.accounts = append(s.accounts, account)
+	if s.codeSender != nil {
+		if s.unverified == nil {
+			s.unverified = make(map[int64]bool)
+		}
+		s.unverified[account.ID] = true
+	}
+	s.audit("account.register", nil, account)
+	s.publish(types.Event{Type: types.EventAccountRegistered, AccountID: account.ID})
 
 	return account, nil
 }
@@ -62,8 +152,36 @@ func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
 //Pay method
 func (s *Service) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
 
+	before := s.accountSnapshot(accountID)
+	payment, err := s.newPayment(accountID, amount, category)
+	if err != nil {
+		return nil, err
+	}
+	s.addPayment(payment, before)
+	return payment, nil
+}
+
+//addPayment stores payment already debited from account
+func (s *Service) addPayment(payment *types.Payment, before interface{}) {
+	s.addPaymentChange(payment, before, s.accountSnapshot(payment.AccountID))
+}
+
+//addPaymentChange is addPayment with account snapshot taken right after debit
+func (s *Service) addPaymentChange(payment *types.Payment, before interface{}, after interface{}) {
+
+	s.payments = append(s.payments, payment)
+	s.audit("payment.pay", before, paymentChange{Account: after, Payment: payment})
+	s.publish(types.Event{Type: types.EventPaymentCreated, AccountID: payment.AccountID, Amount: payment.Amount, Payment: payment})
+}
+
+func (s *Service) newPayment(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	return s.newConfirmedPayment(accountID, amount, category, false)
+}
+
+func (s *Service) newConfirmedPayment(accountID int64, amount types.Money, category types.PaymentCategory, confirmed bool) (*types.Payment, error) {
+
 	if amount <= 0 {
-		return nil, ErrAmountMustBePositive
+		return nil, &Error{Err: ErrAmountMustBePositive, AccountID: accountID, Amount: amount}
 	}
 	var account *types.Account
 	for _, ac := range s.accounts {
@@ -73,10 +191,16 @@ func (s *Service) Pay(accountID int64, amount types.Money, category types.Paymen
 		}
 	}
 	if account == nil {
-		return nil, ErrAccountNotFound
+		return nil, &Error{Err: ErrAccountNotFound, AccountID: accountID}
+	}
+	if err := checkActive(account); err != nil {
+		return nil, err
+	}
+	if err := s.checkVerified(account, amount, confirmed); err != nil {
+		return nil, err
 	}
 	if account.Balance < amount {
-		return nil, ErrNotEnoughtBalance
+		return nil, &Error{Err: ErrNotEnoughtBalance, AccountID: accountID, Amount: amount, Balance: account.Balance}
 	}
 	account.Balance -= amount
 	paymentID := uuid.New().String()
@@ -87,7 +211,6 @@ func (s *Service) Pay(accountID int64, amount types.Money, category types.Paymen
 		Category:  category,
 		Status:    types.PaymentStatusInProgress,
 	}
-	s.payments = append(s.payments, payment)
 	return payment, nil
 }
 
@@ -99,19 +222,36 @@ func (s *Service) FindAccountByID(accountID int64) (*types.Account, error) {
 			return account, nil
 		}
 	}
-	return nil, ErrAccountNotFound
+	return nil, &Error{Err: ErrAccountNotFound, AccountID: accountID}
+}
+
+//Accounts returns copies of all accounts
+func (s *Service) Accounts() []types.Account {
+
+	var accounts []types.Account
+	for _, v := range s.accounts {
+		accounts = append(accounts, *v)
+	}
+	return accounts
 }
 
 //Deposit method
 func (s *Service) Deposit(accountID int64, amount types.Money) error {
 	if amount < 0 {
-		return ErrAmountMustBePositive
+		return &Error{Err: ErrAmountMustBePositive, AccountID: accountID, Amount: amount}
 	}
 	account, err := s.FindAccountByID(accountID)
 	if err != nil {
 		return err
 	}
+	err = checkOpen(account)
+	if err != nil {
+		return err
+	}
+	before := *account
 	account.Balance += amount
+	s.audit("account.deposit", before, account)
+	s.publish(types.Event{Type: types.EventDeposited, AccountID: accountID, Amount: amount})
 	return nil
 
 }
@@ -124,10 +264,11 @@ func (s *Service) FindPaymentByID(paymentID string) (*types.Payment, error) {
 			return payment, nil
 		}
 	}
-	return nil, ErrPaymentNotFound
+	return nil, &Error{Err: ErrPaymentNotFound, PaymentID: paymentID}
 }
 
-//Reject method
+//Reject refunds payment which is in progress or OK, rejected payment returns ErrPaymentNotInProgress.
+//Payment of accepted money request returns ErrPaymentNotRejectable.
 func (s *Service) Reject(paymentID string) error {
 
 	var payment, err = s.FindPaymentByID(paymentID)
@@ -141,9 +282,27 @@ func (s *Service) Reject(paymentID string) error {
 	if er != nil {
 		return er
 	}
+	er = checkOpen(account)
+	if er != nil {
+		return er
+	}
+	// rejected payment is already refunded
+	if payment.Status == types.PaymentStatusFail {
+		return &Error{Err: ErrPaymentNotInProgress, AccountID: account.ID, PaymentID: payment.ID}
+	}
+	// share is already credited to requester, refund would create money
+	if s.paysMoneyRequest(payment.ID) {
+		return &Error{Err: ErrPaymentNotRejectable, AccountID: account.ID, PaymentID: payment.ID}
+	}
 
+	before := paymentChange{Account: account, Payment: payment}
+	beforeJSON := marshalAudit(before)
+	from := payment.Status
 	payment.Status = types.PaymentStatusFail
 	account.Balance += payment.Amount
+	s.reverseRewards(payment)
+	s.auditRaw("payment.reject", beforeJSON, marshalAudit(before))
+	s.publish(types.Event{Type: types.EventPaymentRejected, AccountID: account.ID, Amount: payment.Amount, Payment: payment, From: from})
 
 	return nil
 }
@@ -159,6 +318,7 @@ func (s *Service) Repeat(paymentID string) (*types.Payment, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.audit("payment.repeat", payment, paymentNew)
 	return paymentNew, nil
 }
 
@@ -169,6 +329,10 @@ func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorit
 	if err != nil {
 		return nil, err
 	}
+	err = s.checkFavoriteName(payment.AccountID, "", name)
+	if err != nil {
+		return nil, err
+	}
 
 	favoriteID := uuid.New().String()
 	favorite := &types.Favorite{
@@ -180,6 +344,8 @@ func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorit
 	}
 
 	s.favorites = append(s.favorites, favorite)
+	s.audit("favorite.create", payment, favorite)
+	s.publish(types.Event{Type: types.EventFavoriteCreated, AccountID: favorite.AccountID, Favorite: favorite})
 
 	return favorite, nil
 }
@@ -187,15 +353,9 @@ func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorit
 //PayFromFavorite method
 func (s *Service) PayFromFavorite(favoriteID string) (*types.Payment, error) {
 
-	var favorite *types.Favorite
-	for _, v := range s.favorites {
-		if v.ID == favoriteID {
-			favorite = v
-			break
-		}
-	}
-	if favorite == nil {
-		return nil, ErrFavoriteNotFound
+	favorite, err := s.findFavorite(favoriteID)
+	if err != nil {
+		return nil, err
 	}
 
 	payment, err := s.Pay(favorite.AccountID, favorite.Amount, favorite.Category)
@@ -240,22 +400,36 @@ func (s *Service) ImportFromFile(path string) error {
 	if len(strArray) > 0 {
 		strArray = strArray[:len(strArray)-1]
 	}
-	for _, v := range strArray {
+	for i, v := range strArray {
 		strArrAcount := strings.Split(v, ";")
-		fmt.Println(strArrAcount)
+		if len(strArrAcount) < 3 {
+			return importError(path, i+1, ErrDumpInvalid)
+		}
 
 		id, err := strconv.ParseInt(strArrAcount[0], 10, 64)
 		if err != nil {
-			return err
+			return importError(path, i+1, err)
 		}
 		balance, err := strconv.ParseInt(strArrAcount[2], 10, 64)
 		if err != nil {
-			return err
+			return importError(path, i+1, err)
+		}
+		phone, err := NormalizePhone(types.Phone(strArrAcount[1]))
+		if err != nil {
+			return importError(path, i+1, err)
+		}
+		if found, err := s.FindAccountByPhone(phone); err == nil {
+			// ExportToFile appends, so the same account can be repeated in file
+			if found.ID == id {
+				continue
+			}
+			return importError(path, i+1, &Error{Err: ErrPhoneRegistered, AccountID: id, Phone: phone})
 		}
 		account := &types.Account{
 			ID:      id,
-			Phone:   types.Phone(strArrAcount[1]),
+			Phone:   phone,
 			Balance: types.Money(balance),
+			Status:  types.AccountActive,
 		}
 		s.accounts = append(s.accounts, account)
 	}
@@ -265,193 +439,347 @@ func (s *Service) ImportFromFile(path string) error {
 
 //Export method
 func (s *Service) Export(dir string) error {
+	return s.exportDir(newTracker(context.Background(), "export", 0), dir)
+}
 
-	if len(s.accounts) > 0 {
-		file, _ := os.OpenFile(dir+"/accounts.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+//exportDir builds dumps before writing, so cancelled export leaves files untouched
+func (s *Service) exportDir(t *tracker, dir string) error {
 
-		defer file.Close()
+	t.total = len(s.accounts) + len(s.payments) + len(s.favorites) + len(s.outbox) + s.outboxSpilled + len(s.auditLog)
 
-		var str string
-		for _, v := range s.accounts {
-			str += fmt.Sprint(v.ID) + ";" + string(v.Phone) + ";" + fmt.Sprint(v.Balance) + "\n"
+	var accounts strings.Builder
+	for _, v := range s.accounts {
+		if err := t.check(); err != nil {
+			return err
+		}
+		accounts.WriteString(fmt.Sprint(v.ID) + ";" + string(v.Phone) + ";" + fmt.Sprint(v.Balance) + ";" + string(v.Status) + "\n")
+		t.add(1)
+	}
+
+	var payments strings.Builder
+	for _, v := range s.payments {
+		if err := t.check(); err != nil {
+			return err
 		}
-		_, err = file.WriteString(str)
+		payments.WriteString(fmt.Sprint(v.ID) + ";" + fmt.Sprint(v.AccountID) + ";" + fmt.Sprint(v.Amount) + ";" + fmt.Sprint(v.Category) + ";" + fmt.Sprint(v.Status) + "\n")
+		t.add(1)
 	}
 
-	if len(s.payments) > 0 {
-		file, _ := os.OpenFile(dir+"/payments.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	var favorites strings.Builder
+	for _, v := range s.favorites {
+		if err := t.check(); err != nil {
+			return err
+		}
+		favorites.WriteString(fmt.Sprint(v.ID) + ";" + fmt.Sprint(v.AccountID) + ";" + fmt.Sprint(v.Amount) + ";" + fmt.Sprint(v.Category) + ";" + v.Name + "\n")
+		t.add(1)
+	}
 
-		defer file.Close()
+	outbox, err := s.exportOutbox(t)
+	if err != nil {
+		return err
+	}
+	auditLog, err := s.exportAudit(t)
+	if err != nil {
+		return err
+	}
 
-		var str string
-		for _, v := range s.payments {
-			str += fmt.Sprint(v.ID) + ";" + fmt.Sprint(v.AccountID) + ";" + fmt.Sprint(v.Amount) + ";" + fmt.Sprint(v.Category) + ";" + fmt.Sprint(v.Status) + "\n"
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	dumps := []struct {
+		name    string
+		content string
+	}{
+		{"accounts.dump", accounts.String()},
+		{"payments.dump", payments.String()},
+		{"favorites.dump", favorites.String()},
+		{"audit.dump", auditLog},
+	}
+	for _, v := range dumps {
+		if v.content == "" {
+			continue
+		}
+		err := ioutil.WriteFile(dir+"/"+v.name, []byte(v.content), 0666)
+		if err != nil {
+			return err
 		}
-		_, err = file.WriteString(str)
 	}
+	return ioutil.WriteFile(dir+"/outbox.dump", []byte(outbox), 0666)
+}
 
-	if len(s.favorites) > 0 {
-		file, _ := os.OpenFile(dir+"/favorites.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+//Import method
+func (s *Service) Import(dir string) error {
+	return s.importWith(newTracker(context.Background(), "import", 0), dir)
+}
 
-		defer file.Close()
+func (s *Service) importWith(t *tracker, dir string) error {
 
-		var str string
-		for _, v := range s.favorites {
-			str += fmt.Sprint(v.ID) + ";" + fmt.Sprint(v.AccountID) + ";" + fmt.Sprint(v.Amount) + ";" + fmt.Sprint(v.Category) + "\n"
+	before := s.stateCounts()
+	staged := s.stage()
+	err := staged.importDir(t, dir)
+	if err != nil {
+		// nothing is applied, but failed attempt is audited too
+		s.audit("data.import_failed", before, s.stateCounts())
+		return err
+	}
+	s.apply(staged)
+	s.audit("data.import", before, s.stateCounts())
+	s.publish(types.Event{Type: types.EventImported})
+	return nil
+}
+
+//stage returns copy of data which import changes, so import is applied only when it is complete
+func (s *Service) stage() *Service {
+
+	staged := &Service{
+		nextAccountID: s.nextAccountID,
+		eventSeq:      s.eventSeq,
+		auditLog:      s.auditLog,
+	}
+	for _, v := range s.accounts {
+		account := *v
+		staged.accounts = append(staged.accounts, &account)
+	}
+	for _, v := range s.payments {
+		payment := *v
+		staged.payments = append(staged.payments, &payment)
+	}
+	for _, v := range s.favorites {
+		favorite := *v
+		staged.favorites = append(staged.favorites, &favorite)
+	}
+	for _, v := range s.outbox {
+		entry := *v
+		staged.outbox = append(staged.outbox, &entry)
+	}
+	return staged
+}
+
+//apply copies staged data back, items which already existed keep their pointers
+func (s *Service) apply(staged *Service) {
+
+	s.nextAccountID = staged.nextAccountID
+	s.eventSeq = staged.eventSeq
+	s.auditLog = staged.auditLog
+	for i, v := range staged.accounts {
+		if i < len(s.accounts) {
+			*s.accounts[i] = *v
+			continue
+		}
+		s.accounts = append(s.accounts, v)
+	}
+	for i, v := range staged.payments {
+		if i < len(s.payments) {
+			*s.payments[i] = *v
+			continue
+		}
+		s.payments = append(s.payments, v)
+	}
+	for i, v := range staged.favorites {
+		if i < len(s.favorites) {
+			*s.favorites[i] = *v
+			continue
+		}
+		s.favorites = append(s.favorites, v)
+	}
+	for i, v := range staged.outbox {
+		if i < len(s.outbox) {
+			s.outbox[i] = v
+			continue
 		}
-		_, err = file.WriteString(str)
+		s.outbox = append(s.outbox, v)
 	}
+}
 
-	return nil
+//readDump returns lines of dump file, nil when file does not exist
+func readDump(dir string, name string) ([]string, error) {
+
+	content, err := ioutil.ReadFile(dir + "/" + name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	return lines[:len(lines)-1], nil
 }
 
-//Import method
-func (s *Service) Import(dir string) error {
+//importDir reads all dumps first, so total of tracker is known before first line is imported
+func (s *Service) importDir(t *tracker, dir string) error {
+
+	accounts, err := readDump(dir, "accounts.dump")
+	if err != nil {
+		return err
+	}
+	payments, err := readDump(dir, "payments.dump")
+	if err != nil {
+		return err
+	}
+	favorites, err := readDump(dir, "favorites.dump")
+	if err != nil {
+		return err
+	}
+	outbox, err := readDump(dir, "outbox.dump")
+	if err != nil {
+		return err
+	}
+	auditLog, err := readDump(dir, "audit.dump")
+	if err != nil {
+		return err
+	}
+	t.total = len(accounts) + len(payments) + len(favorites) + len(outbox) + len(auditLog)
 
-	_, err := os.Stat(dir + "/accounts.dump")
+	// audit is checked first, so tampered dump changes nothing
+	err = s.importAudit(t, auditLog)
+	if err != nil {
+		return err
+	}
 
-	if err == nil {
-		content, err := ioutil.ReadFile(dir + "/accounts.dump")
-		if err != nil {
+	for i, v := range accounts {
+		if err := t.check(); err != nil {
 			return err
 		}
-
-		strArray := strings.Split(string(content), "\n")
-		if len(strArray) > 0 {
-			strArray = strArray[:len(strArray)-1]
+		strArrAcount := strings.Split(v, ";")
+		if len(strArrAcount) < 3 {
+			return importError("accounts.dump", i+1, ErrDumpInvalid)
 		}
-		for _, v := range strArray {
-			strArrAcount := strings.Split(v, ";")
-			fmt.Println(strArrAcount)
 
-			id, err := strconv.ParseInt(strArrAcount[0], 10, 64)
-			if err != nil {
-				return err
-			}
-			balance, err := strconv.ParseInt(strArrAcount[2], 10, 64)
-			if err != nil {
-				return err
-			}
-			flag := true
-			for _, v := range s.accounts {
-				if v.ID == id {
-					v.Phone = types.Phone(strArrAcount[1])
-					v.Balance = types.Money(balance)
-					flag = false
-				}
+		id, err := strconv.ParseInt(strArrAcount[0], 10, 64)
+		if err != nil {
+			return importError("accounts.dump", i+1, err)
+		}
+		balance, err := strconv.ParseInt(strArrAcount[2], 10, 64)
+		if err != nil {
+			return importError("accounts.dump", i+1, err)
+		}
+		phone, err := NormalizePhone(types.Phone(strArrAcount[1]))
+		if err != nil {
+			return importError("accounts.dump", i+1, err)
+		}
+		if owner, err := s.FindAccountByPhone(phone); err == nil && owner.ID != id {
+			return importError("accounts.dump", i+1, &Error{Err: ErrPhoneRegistered, AccountID: id, Phone: phone})
+		}
+		if id > s.nextAccountID {
+			s.nextAccountID = id
+		}
+		status := types.AccountActive
+		if len(strArrAcount) > 3 && strArrAcount[3] != "" {
+			status = types.AccountStatus(strArrAcount[3])
+		}
+		if !validStatus(status) {
+			return importError("accounts.dump", i+1, &Error{Err: ErrDumpInvalid, AccountID: id})
+		}
+		flag := true
+		for _, v := range s.accounts {
+			if v.ID == id {
+				v.Phone = phone
+				v.Balance = types.Money(balance)
+				v.Status = status
+				flag = false
 			}
-			if flag {
-				account := &types.Account{
-					ID:      id,
-					Phone:   types.Phone(strArrAcount[1]),
-					Balance: types.Money(balance),
-				}
-				s.accounts = append(s.accounts, account)
+		}
+		if flag {
+			account := &types.Account{
+				ID:      id,
+				Phone:   phone,
+				Balance: types.Money(balance),
+				Status:  status,
 			}
+			s.accounts = append(s.accounts, account)
 		}
+		t.add(1)
 	}
 
-	_, err1 := os.Stat(dir + "/payments.dump")
-
-	if err1 == nil {
-		content, err := ioutil.ReadFile(dir + "/payments.dump")
-		if err != nil {
+	for i, v := range payments {
+		if err := t.check(); err != nil {
 			return err
 		}
-
-		strArray := strings.Split(string(content), "\n")
-		if len(strArray) > 0 {
-			strArray = strArray[:len(strArray)-1]
+		strArrAcount := strings.Split(v, ";")
+		if len(strArrAcount) < 5 {
+			return importError("payments.dump", i+1, ErrDumpInvalid)
 		}
-		for _, v := range strArray {
-			strArrAcount := strings.Split(v, ";")
-			fmt.Println(strArrAcount)
 
-			id := strArrAcount[0]
-			if err != nil {
-				return err
-			}
-			aid, err := strconv.ParseInt(strArrAcount[1], 10, 64)
-			if err != nil {
-				return err
-			}
-			amount, err := strconv.ParseInt(strArrAcount[2], 10, 64)
-			if err != nil {
-				return err
-			}
-			flag := true
-			for _, v := range s.payments {
-				if v.ID == id {
-					v.AccountID = aid
-					v.Amount = types.Money(amount)
-					v.Category = types.PaymentCategory(strArrAcount[3])
-					v.Status = types.PaymentStatus(strArrAcount[4])
-					flag = false
-				}
+		id := strArrAcount[0]
+		aid, err := strconv.ParseInt(strArrAcount[1], 10, 64)
+		if err != nil {
+			return importError("payments.dump", i+1, err)
+		}
+		amount, err := strconv.ParseInt(strArrAcount[2], 10, 64)
+		if err != nil {
+			return importError("payments.dump", i+1, err)
+		}
+		flag := true
+		for _, v := range s.payments {
+			if v.ID == id {
+				v.AccountID = aid
+				v.Amount = types.Money(amount)
+				v.Category = types.PaymentCategory(strArrAcount[3])
+				v.Status = types.PaymentStatus(strArrAcount[4])
+				flag = false
 			}
-			if flag {
-				data := &types.Payment{
-					ID:        id,
-					AccountID: aid,
-					Amount:    types.Money(amount),
-					Category:  types.PaymentCategory(strArrAcount[3]),
-					Status:    types.PaymentStatus(strArrAcount[4]),
-				}
-				s.payments = append(s.payments, data)
+		}
+		if flag {
+			data := &types.Payment{
+				ID:        id,
+				AccountID: aid,
+				Amount:    types.Money(amount),
+				Category:  types.PaymentCategory(strArrAcount[3]),
+				Status:    types.PaymentStatus(strArrAcount[4]),
 			}
+			s.payments = append(s.payments, data)
 		}
+		t.add(1)
 	}
 
-	_, err2 := os.Stat(dir + "/favorites.dump")
-
-	if err2 == nil {
-		content, err := ioutil.ReadFile(dir + "/favorites.dump")
-		if err != nil {
+	for i, v := range favorites {
+		if err := t.check(); err != nil {
 			return err
 		}
-
-		strArray := strings.Split(string(content), "\n")
-		if len(strArray) > 0 {
-			strArray = strArray[:len(strArray)-1]
+		strArrAcount := strings.Split(v, ";")
+		if len(strArrAcount) < 4 {
+			return importError("favorites.dump", i+1, ErrDumpInvalid)
 		}
-		for _, v := range strArray {
-			strArrAcount := strings.Split(v, ";")
-			fmt.Println(strArrAcount)
 
-			id := strArrAcount[0]
-			if err != nil {
-				return err
-			}
-			aid, err := strconv.ParseInt(strArrAcount[1], 10, 64)
-			if err != nil {
-				return err
-			}
-			amount, err := strconv.ParseInt(strArrAcount[2], 10, 64)
-			if err != nil {
-				return err
-			}
-			flag := true
-			for _, v := range s.favorites {
-				if v.ID == id {
-					v.AccountID = aid
-					v.Amount = types.Money(amount)
-					v.Category = types.PaymentCategory(strArrAcount[3])
-					flag = false
-				}
+		id := strArrAcount[0]
+		aid, err := strconv.ParseInt(strArrAcount[1], 10, 64)
+		if err != nil {
+			return importError("favorites.dump", i+1, err)
+		}
+		amount, err := strconv.ParseInt(strArrAcount[2], 10, 64)
+		if err != nil {
+			return importError("favorites.dump", i+1, err)
+		}
+		name := ""
+		if len(strArrAcount) > 4 {
+			name = strArrAcount[4]
+		}
+		flag := true
+		for _, v := range s.favorites {
+			if v.ID == id {
+				v.AccountID = aid
+				v.Amount = types.Money(amount)
+				v.Category = types.PaymentCategory(strArrAcount[3])
+				v.Name = name
+				flag = false
 			}
-			if flag {
-				data := &types.Favorite{
-					ID:        id,
-					AccountID: aid,
-					Amount:    types.Money(amount),
-					Category:  types.PaymentCategory(strArrAcount[3]),
-				}
-				s.favorites = append(s.favorites, data)
+		}
+		if flag {
+			data := &types.Favorite{
+				ID:        id,
+				AccountID: aid,
+				Name:      name,
+				Amount:    types.Money(amount),
+				Category:  types.PaymentCategory(strArrAcount[3]),
 			}
+			s.favorites = append(s.favorites, data)
 		}
+		t.add(1)
 	}
 
-	return nil
+	return s.importOutbox(t, outbox)
 }
 
 //ExportAccountHistory ....
@@ -481,90 +809,46 @@ func (s *Service) ExportAccountHistory(accountID int64) ([]types.Payment, error)
 
 //HistoryToFiles ...
 func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records int) error {
+	return s.historyToFiles(newTracker(context.Background(), "history to files", len(payments)), payments, dir, records)
+}
 
-	if len(payments) > 0 {
-		if len(payments) <= records {
-			file, _ := os.OpenFile(dir+"/payments.dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
-			defer file.Close()
+//historyToFiles builds all files before writing, so cancelled call leaves dir untouched
+func (s *Service) historyToFiles(t *tracker, payments []types.Payment, dir string, records int) error {
 
-			var str string
-			for _, v := range payments {
-				str += fmt.Sprint(v.ID) + ";" + fmt.Sprint(v.AccountID) + ";" + fmt.Sprint(v.Amount) + ";" + fmt.Sprint(v.Category) + ";" + fmt.Sprint(v.Status) + "\n"
-			}
-			file.WriteString(str)
-		} else {
-
-			var str string
-			k := 0
-			t := 1
-			var file *os.File
-			for _, v := range payments {
-				if k == 0 {
-					file, _ = os.OpenFile(dir+"/payments"+fmt.Sprint(t)+".dump", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
-				}
-				k++
-				str = fmt.Sprint(v.ID) + ";" + fmt.Sprint(v.AccountID) + ";" + fmt.Sprint(v.Amount) + ";" + fmt.Sprint(v.Category) + ";" + fmt.Sprint(v.Status) + "\n"
-				_, err = file.WriteString(str)
-				if k == records {
-					str = ""
-					t++
-					k = 0
-					file.Close()
-				}
-			}
-			/* _, err = file.WriteString(str)
-			if err == nil{
-				file.Close()
-			} */
+	if len(payments) == 0 {
+		return nil
+	}
 
+	var files []string
+	var str strings.Builder
+	for i, v := range payments {
+		if err := t.check(); err != nil {
+			return err
+		}
+		str.WriteString(fmt.Sprint(v.ID) + ";" + fmt.Sprint(v.AccountID) + ";" + fmt.Sprint(v.Amount) + ";" + fmt.Sprint(v.Category) + ";" + fmt.Sprint(v.Status) + "\n")
+		if (records > 0 && (i+1)%records == 0) || i == len(payments)-1 {
+			files = append(files, str.String())
+			str.Reset()
 		}
+		t.add(1)
 	}
 
+	// files are written best effort, as before
+	if len(payments) <= records {
+		ioutil.WriteFile(dir+"/payments.dump", []byte(files[0]), 0666)
+		return nil
+	}
+	for i, v := range files {
+		ioutil.WriteFile(dir+"/payments"+fmt.Sprint(i+1)+".dump", []byte(v), 0666)
+	}
 	return nil
 }
 
-//SumPayments ...
+//SumPayments sums payments in goroutines workers, 0 means runtime.NumCPU()
 func (s *Service) SumPayments(goroutines int) types.Money {
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	sum := int64(0)
-	kol := 0
-	i := 0
-	if goroutines == 0 {
-		kol = len(s.payments)
-	} else {
-		kol = int(len(s.payments) / goroutines)
-	}
-	for i = 0; i < goroutines-1; i++ {
-		wg.Add(1)
-		go func(index int) {
-			defer wg.Done()
-			val := int64(0)
-			payments := s.payments[index*kol : (index+1)*kol]
-			for _, payment := range payments {
-				val += int64(payment.Amount)
-			}
-			mu.Lock()
-			sum += val
-			mu.Unlock()
-
-		}(i)
-	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		val := int64(0)
-		payments := s.payments[i*kol:]
-		for _, payment := range payments {
-			val += int64(payment.Amount)
-		}
-		mu.Lock()
-		sum += val
-		mu.Unlock()
 
-	}()
-	wg.Wait()
-	return types.Money(sum)
+	sum, _ := s.query("sum payments", goroutines).Sum(context.Background())
+	return sum
 }
 
 //FilterPayments ...
@@ -582,185 +866,7 @@ func (s *Service) FilterPayments(accountID int64, goroutines int) ([]types.Payme
 
 }
 
-//FilterPaymentsByFn ...
+//FilterPaymentsByFn filters payments in goroutines workers, 0 means runtime.NumCPU(), payments keep their order
 func (s *Service) FilterPaymentsByFn(filter func(payment types.Payment) bool, goroutines int) ([]types.Payment, error) {
-
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	kol := 0
-	i := 0
-	var ps []types.Payment
-	if goroutines == 0 {
-		kol = len(s.payments)
-	} else {
-		kol = int(len(s.payments) / goroutines)
-	}
-	for i = 0; i < goroutines-1; i++ {
-		wg.Add(1)
-		go func(index int) {
-			defer wg.Done()
-			var pays []types.Payment
-			payments := s.payments[index*kol : (index+1)*kol]
-			for _, v := range payments {
-				p := types.Payment{
-					ID:        v.ID,
-					AccountID: v.AccountID,
-					Amount:    v.Amount,
-					Category:  v.Category,
-					Status:    v.Status,
-				}
-
-				if filter(p) {
-					pays = append(pays, p)
-				}
-			}
-			mu.Lock()
-			ps = append(ps, pays...)
-			mu.Unlock()
-
-		}(i)
-	}
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		var pays []types.Payment
-		payments := s.payments[i*kol:]
-		for _, v := range payments {
-
-			p := types.Payment{
-				ID:        v.ID,
-				AccountID: v.AccountID,
-				Amount:    v.Amount,
-				Category:  v.Category,
-				Status:    v.Status,
-			}
-
-			if filter(p) {
-				pays = append(pays, p)
-			}
-		}
-		mu.Lock()
-		ps = append(ps, pays...)
-		mu.Unlock()
-
-	}()
-	wg.Wait()
-	if len(ps) == 0 {
-		return nil, nil
-	}
-	return ps, nil
-}
-
-/*
-//SumPaymentsWithProgress ...
-func (s *Service) SumPaymentsWithProgress() <-chan types.Progress {
-	const sizeOfBlock = 100_000
-	var goroutines int = len(s.payments) / sizeOfBlock
-	var sizeOfChannels int = len(s.payments) / sizeOfBlock
-	if goroutines <= 0 && len(s.payments) > 0 {
-		goroutines = 1
-		sizeOfChannels = 1
-	}
-	channels := make([]<-chan types.Progress, sizeOfChannels)
-	for i := 0; i < goroutines; i++ {
-		var l int = i * sizeOfBlock
-		var r int = (i + 1) * sizeOfBlock
-		if r > len(s.payments) {
-			r = len(s.payments)
-		}
-		ch := make(chan types.Progress)
-		go func(ch chan<- types.Progress, data []*types.Payment) {
-			defer close(ch)
-			var total types.Money = 0
-			for _, payment := range data {
-				total += payment.Amount
-			}
-			ch <- types.Progress{
-				Part:   len(data),
-				Result: total,
-			}
-		}(ch, s.payments[l:r])
-		channels[i] = ch
-	}
-	return merge(channels)
-} */
-
-//SumPaymentsWithProgress ...
-func (s *Service) SumPaymentsWithProgress() <-chan types.Progress {
-
-	ch := make(chan types.Progress)
-
-	size := 100_000
-	parts := len(s.payments) / size
-	wg := sync.WaitGroup{}
-	i := 0
-	if parts < 1 {
-		parts = 1
-	}
-	for i := 0; i < parts; i++ {
-		wg.Add(1)
-		var payments []*types.Payment
-		if len(s.payments) < size {
-			payments = s.payments
-		} else {
-			payments = s.payments[i*size : (i+1)*size]
-		}
-		go func(ch chan types.Progress, data []*types.Payment) {
-			defer wg.Done()
-			val := types.Money(0)
-			for _, v := range data {
-				val += v.Amount
-			}
-			if len(s.payments) < size {
-				ch <- types.Progress{
-					Part:   len(data),
-					Result: val,
-				}
-			}
-
-		}(ch, payments)
-	}
-	if len(s.payments) > size {
-		wg.Add(1)
-		payments := s.payments[i*size:]
-		go func(ch chan types.Progress, data []*types.Payment) {
-			defer wg.Done()
-			val := types.Money(0)
-			for _, v := range data {
-				val += v.Amount
-			}
-			ch <- types.Progress{
-				Part:   len(data),
-				Result: val,
-			}
-
-		}(ch, payments)
-	}
-
-	go func() {
-		defer close(ch)
-		wg.Wait()
-	}()
-
-	return ch
-}
-
-func merge(channels []<-chan types.Progress) <-chan types.Progress {
-	wg := sync.WaitGroup{}
-	wg.Add(len(channels))
-	merged := make(chan types.Progress)
-	for _, ch := range channels {
-		go func(ch <-chan types.Progress) {
-			defer wg.Done()
-			for val := range ch {
-				merged <- val
-			}
-		}(ch)
-	}
-	go func() {
-		defer close(merged)
-		wg.Wait()
-	}()
-	return merged
+	return s.query("filter payments", goroutines).Filter(filter).Collect(context.Background())
 }
@@ -1,7 +1,10 @@
 package wallet
 
 import (
+	"errors"
+	"io/ioutil"
 	"log"
+	"os"
 	"testing"
 
 	"github.com/khushbakhtmahkamov/wallet/pkg/types"
@@ -210,6 +213,53 @@ func TestService_ExportImport_success_user(t *testing.T) {
 
 }
 
+func TestService_Import_nextAccountID_user(t *testing.T) {
+	var svc Service
+
+	svc.RegisterAccount("+992000000001")
+	svc.RegisterAccount("+992000000002")
+
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Fatalf("method Export returned not nil error, err => %v", err)
+	}
+
+	var imported Service
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatalf("method Import returned not nil error, err => %v", err)
+	}
+	account, err := imported.RegisterAccount("+992000000003")
+	if err != nil {
+		t.Fatalf("method RegisterAccount returned not nil error, err => %v", err)
+	}
+	if account.ID != 3 {
+		t.Errorf("wrong account id after import => %v", account.ID)
+	}
+}
+
+func TestService_Accounts_user(t *testing.T) {
+	var svc Service
+
+	svc.RegisterAccount("+992000000001")
+	account, _ := svc.RegisterAccount("+992000000002")
+
+	accounts := svc.Accounts()
+	if len(accounts) != 2 || accounts[1].ID != account.ID {
+		t.Fatalf("wrong accounts => %v", accounts)
+	}
+	accounts[1].Balance = 100
+	if account.Balance != 0 {
+		t.Errorf("method Accounts returned not copies")
+	}
+}
+
 func TestService_ExportHistory_success_user(t *testing.T) {
 	var svc Service
 
@@ -355,3 +405,31 @@ func BenchmarkSumPaymentsWithProgress_user(b *testing.B) {
 	log.Println("=======>>>>>", s)
 
 }
+
+func TestService_RegisterAccount_normalizedPhone_user(t *testing.T) {
+	var svc Service
+
+	account, err := svc.RegisterAccount("+992928393813")
+	if err != nil {
+		t.Fatalf("method RegisterAccount returned not nil error, error => %v", err)
+	}
+
+	_, err = svc.RegisterAccount("992928393813")
+	if !errors.Is(err, ErrPhoneRegistered) {
+		t.Errorf("method RegisterAccount returned wrong error, error => %v", err)
+	}
+	_, err = svc.RegisterAccount("92 839 38 13")
+	if !errors.Is(err, ErrPhoneRegistered) {
+		t.Errorf("method RegisterAccount returned wrong error, error => %v", err)
+	}
+
+	_, err = svc.RegisterAccount("+99292839")
+	if !errors.Is(err, ErrPhoneInvalid) {
+		t.Errorf("method RegisterAccount returned wrong error, error => %v", err)
+	}
+
+	found, err := svc.FindAccountByPhone("(92) 839-38-13")
+	if err != nil || found.ID != account.ID {
+		t.Errorf("method FindAccountByPhone returned wrong account => %v, error => %v", found, err)
+	}
+}
@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrAccountNotVerified -- account phone is not verified
+var ErrAccountNotVerified = errors.New("account is not verified")
+
+//ErrAccountVerified -- account already verified
+var ErrAccountVerified = errors.New("account already verified")
+
+//SetVerificationThreshold sets amount above which payment must be confirmed with code, 0 turns it off
+func (s *Service) SetVerificationThreshold(amount types.Money) {
+	s.payThreshold = amount
+}
+
+//SendVerificationCode sends new registration code to account phone
+func (s *Service) SendVerificationCode(accountID int64) error {
+
+	if s.codeSender == nil {
+		return ErrVerificationRequired
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if !s.unverified[accountID] {
+		return ErrAccountVerified
+	}
+
+	return s.issueCode(verifyKey(accountID), account.Phone)
+}
+
+//VerifyAccount confirms account phone with code sent on registration
+func (s *Service) VerifyAccount(accountID int64, code string) error {
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if !s.unverified[accountID] {
+		return ErrAccountVerified
+	}
+	_, err = s.verifyCode(verifyKey(accountID), code)
+	if err != nil {
+		return err
+	}
+
+	delete(s.unverified, accountID)
+	return nil
+}
+
+//IsVerified method
+func (s *Service) IsVerified(accountID int64) (bool, error) {
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return false, err
+	}
+	return !s.unverified[accountID], nil
+}
+
+//SendPaymentCode sends code which confirms exactly this payment
+func (s *Service) SendPaymentCode(accountID int64, amount types.Money, category types.PaymentCategory) error {
+
+	if s.codeSender == nil {
+		return ErrVerificationRequired
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	return s.issueCode(paymentKey(accountID, amount, category), account.Phone)
+}
+
+//PayWithCode pays with code sent by SendPaymentCode
+func (s *Service) PayWithCode(accountID int64, amount types.Money, category types.PaymentCategory, code string) (*types.Payment, error) {
+
+	_, err := s.verifyCode(paymentKey(accountID, amount, category), code)
+	if err != nil {
+		return nil, err
+	}
+
+	before := s.accountSnapshot(accountID)
+	payment, err := s.newConfirmedPayment(accountID, amount, category, true)
+	if err != nil {
+		return nil, err
+	}
+	s.addPayment(payment, before)
+	return payment, nil
+}
+
+func (s *Service) checkVerified(account *types.Account, amount types.Money, confirmed bool) error {
+
+	if s.unverified[account.ID] {
+		return &Error{Err: ErrAccountNotVerified, AccountID: account.ID}
+	}
+	if !confirmed && s.payThreshold > 0 && amount > s.payThreshold {
+		return &Error{Err: ErrVerificationRequired, AccountID: account.ID, Amount: amount}
+	}
+	return nil
+}
+
+func verifyKey(accountID int64) string {
+	return fmt.Sprint("verify:", accountID)
+}
+
+func paymentKey(accountID int64, amount types.Money, category types.PaymentCategory) string {
+	return fmt.Sprint("pay:", accountID, ":", amount, ":", category)
+}
@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestService_VerifyAccount_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+	sender := &MemoryCodeSender{}
+	svc.SetCodeSender(sender)
+	svc.SetCodePolicy(time.Minute, 2)
+
+	account, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Fatalf("method RegisterAccount returned not nil error, error => %v", err)
+	}
+	svc.Deposit(account.ID, 100_00)
+
+	_, err = svc.Pay(account.ID, 10_00, "Cafe")
+	if !errors.Is(err, ErrAccountNotVerified) {
+		t.Errorf("method Pay returned wrong error, error => %v", err)
+	}
+
+	svc.VerifyAccount(account.ID, "wrong")
+	err = svc.VerifyAccount(account.ID, "wrong")
+	if !errors.Is(err, ErrCodeAttempts) {
+		t.Errorf("method VerifyAccount returned wrong error, error => %v", err)
+	}
+	err = svc.VerifyAccount(account.ID, sender.LastCode(account.Phone))
+	if !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("code was not removed after attempts, error => %v", err)
+	}
+
+	// resend does not give new attempts until failures are reset
+	err = svc.SendVerificationCode(account.ID)
+	if !errors.Is(err, ErrCodeAttempts) {
+		t.Errorf("method SendVerificationCode returned wrong error, error => %v", err)
+	}
+	clock.now = clock.now.Add(time.Minute)
+
+	err = svc.SendVerificationCode(account.ID)
+	if err != nil {
+		t.Fatalf("method SendVerificationCode returned not nil error, error => %v", err)
+	}
+	err = svc.VerifyAccount(account.ID, sender.LastCode(account.Phone))
+	if err != nil {
+		t.Fatalf("method VerifyAccount returned not nil error, error => %v", err)
+	}
+
+	_, err = svc.Pay(account.ID, 10_00, "Cafe")
+	if err != nil {
+		t.Errorf("method Pay returned not nil error, error => %v", err)
+	}
+}
+
+func TestService_PayWithCode_user(t *testing.T) {
+	var svc Service
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+
+	sender := &MemoryCodeSender{}
+	svc.SetCodeSender(sender)
+	svc.SetVerificationThreshold(50_00)
+
+	_, err := svc.Pay(account.ID, 50_00, "Cafe")
+	if err != nil {
+		t.Errorf("method Pay returned not nil error, error => %v", err)
+	}
+	_, err = svc.Pay(account.ID, 50_01, "Cafe")
+	if !errors.Is(err, ErrVerificationRequired) {
+		t.Errorf("method Pay returned wrong error, error => %v", err)
+	}
+
+	svc.SendPaymentCode(account.ID, 50_00, "Shop")
+	code := sender.LastCode(account.Phone)
+
+	_, err = svc.PayWithCode(account.ID, 40_00, "Shop", code)
+	if !errors.Is(err, ErrCodeNotFound) {
+		t.Errorf("code accepted for other payment, error => %v", err)
+	}
+
+	payment, err := svc.PayWithCode(account.ID, 50_00, "Shop", code)
+	if err != nil {
+		t.Fatalf("method PayWithCode returned not nil error, error => %v", err)
+	}
+	if payment.Amount != 50_00 || account.Balance != 0 {
+		t.Errorf("wrong payment => %v, balance => %v", payment, account.Balance)
+	}
+}
+
+func TestService_SendVerificationCode_keepsFailures_user(t *testing.T) {
+	var svc Service
+	sender := &MemoryCodeSender{}
+	svc.SetCodeSender(sender)
+	svc.SetCodePolicy(time.Minute, 3)
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	for i := 0; i < 2; i++ {
+		if err := svc.VerifyAccount(account.ID, "wrong"); !errors.Is(err, ErrCodeInvalid) {
+			t.Fatalf("attempt %d returned wrong error, error => %v", i, err)
+		}
+		svc.SendVerificationCode(account.ID)
+	}
+
+	err := svc.VerifyAccount(account.ID, "wrong")
+	if !errors.Is(err, ErrCodeAttempts) {
+		t.Errorf("resend gave new attempts, error => %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+//ErrWebhookNotFound -- webhook not found
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+//ErrWebhookInvalid -- webhook url or secret is invalid
+var ErrWebhookInvalid = errors.New("webhook is invalid")
+
+//WebhookSignatureHeader carries hex HMAC-SHA256 of body
+const WebhookSignatureHeader = "X-Wallet-Signature"
+
+const webhookMaxAttempts = 5
+
+//webhookTimeout limits request of default client, so hanging endpoint does not stop delivery
+const webhookTimeout = 10 * time.Second
+
+var defaultWebhookClient = &http.Client{Timeout: webhookTimeout}
+
+//WebhookPayload is JSON body sent to webhook
+type WebhookPayload struct {
+	EventID string              `json:"event_id"`
+	Type    types.EventType     `json:"type"`
+	From    types.PaymentStatus `json:"from"`
+	To      types.PaymentStatus `json:"to"`
+	At      time.Time           `json:"at"`
+	Payment WebhookPayment      `json:"payment"`
+}
+
+//WebhookPayment describes payment in webhook payload
+type WebhookPayment struct {
+	ID        string                `json:"id"`
+	AccountID int64                 `json:"account_id"`
+	Amount    types.Money           `json:"amount"`
+	Category  types.PaymentCategory `json:"category"`
+	Status    types.PaymentStatus   `json:"status"`
+}
+
+//RegisterWebhook adds endpoint for payment status changes, accountID 0 and empty category match all.
+//Secret is kept only inside service to sign payloads, it is not returned and not exported.
+func (s *Service) RegisterWebhook(endpoint string, secret string, accountID int64, category types.PaymentCategory) (*types.Webhook, error) {
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || secret == "" {
+		return nil, ErrWebhookInvalid
+	}
+	if accountID != 0 {
+		_, err := s.FindAccountByID(accountID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	webhook := &types.Webhook{
+		ID:        uuid.New().String(),
+		URL:       endpoint,
+		AccountID: accountID,
+		Category:  category,
+	}
+	s.webhooks = append(s.webhooks, webhook)
+	if s.webhookKeys == nil {
+		s.webhookKeys = make(map[string]string)
+	}
+	s.webhookKeys[webhook.ID] = secret
+
+	copied := *webhook
+	return &copied, nil
+}
+
+//RemoveWebhook removes endpoint and its pending deliveries
+func (s *Service) RemoveWebhook(webhookID string) error {
+
+	for i, v := range s.webhooks {
+		if v.ID == webhookID {
+			s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+			delete(s.webhookKeys, webhookID)
+
+			var deliveries []*types.WebhookDelivery
+			for _, d := range s.deliveries {
+				if d.WebhookID != webhookID {
+					deliveries = append(deliveries, d)
+				}
+			}
+			s.deliveries = deliveries
+			return nil
+		}
+	}
+	return ErrWebhookNotFound
+}
+
+//WebhookDeadLetters returns deliveries which failed all attempts
+func (s *Service) WebhookDeadLetters() []types.WebhookDelivery {
+
+	var letters []types.WebhookDelivery
+	for _, v := range s.deadLetters {
+		letters = append(letters, *v)
+	}
+	return letters
+}
+
+//DeliverWebhooks posts due deliveries and returns count of successful ones, nil client has timeout of 10 seconds.
+//Failed delivery is retried with exponential backoff and moved to dead letters after last attempt.
+//While delivery waits for retry later deliveries of its webhook wait too, so webhook gets events in order.
+func (s *Service) DeliverWebhooks(client *http.Client) int {
+
+	delivered, _ := s.deliverWebhooks(newTracker(context.Background(), "deliver webhooks", 0), client, noLocker{})
+	return delivered
+}
+
+//webhookPost is due delivery with data needed to post it without service lock
+type webhookPost struct {
+	delivery  *types.WebhookDelivery
+	copied    types.WebhookDelivery
+	webhook   types.Webhook
+	secret    string
+	attempted bool
+	err       error
+}
+
+//deliverWebhooks unlocks outside while requests are sent, so slow receiver does not hold service
+func (s *Service) deliverWebhooks(t *tracker, client *http.Client, outside sync.Locker) (int, error) {
+
+	if client == nil {
+		client = defaultWebhookClient
+	}
+
+	now := s.currentTime()
+	blocked := make(map[string]bool)
+	finished := make(map[*types.WebhookDelivery]bool)
+	var due []*webhookPost
+	for _, v := range s.deliveries {
+		if blocked[v.WebhookID] || v.NextAttempt.After(now) {
+			blocked[v.WebhookID] = true
+			continue
+		}
+		webhook := s.findWebhook(v.WebhookID)
+		if webhook == nil {
+			finished[v] = true
+			continue
+		}
+		due = append(due, &webhookPost{delivery: v, copied: *v, webhook: *webhook, secret: s.webhookKeys[webhook.ID]})
+	}
+	t.total = len(due)
+
+	outside.Unlock()
+	failed := make(map[string]bool)
+	for _, v := range due {
+		if t.check() != nil {
+			break
+		}
+		t.add(1)
+		if failed[v.webhook.ID] {
+			continue
+		}
+		v.attempted = true
+		v.err = postWebhook(t.ctx, client, &v.webhook, v.secret, &v.copied)
+		if v.err != nil {
+			failed[v.webhook.ID] = true
+		}
+	}
+	outside.Lock()
+
+	delivered := 0
+	for _, v := range due {
+		if !v.attempted {
+			continue
+		}
+		v.delivery.Attempts++
+		if v.err == nil {
+			delivered++
+			finished[v.delivery] = true
+			continue
+		}
+		v.delivery.LastError = v.err.Error()
+		if v.delivery.Attempts >= webhookMaxAttempts {
+			s.deadLetters = append(s.deadLetters, v.delivery)
+			finished[v.delivery] = true
+			continue
+		}
+		v.delivery.NextAttempt = now.Add(backoff(v.delivery.Attempts))
+	}
+	// deliveries may be added or removed while requests are sent, so finished ones are taken out of current list
+	var pending []*types.WebhookDelivery
+	for _, v := range s.deliveries {
+		if !finished[v] {
+			pending = append(pending, v)
+		}
+	}
+	s.deliveries = pending
+	// requests failed by deadline of ctx are reported too
+	return delivered, t.check()
+}
+
+//StartWebhooks runs DeliverWebhooks on every tick until returned stop is called.
+//Every tick holds Locker, but requests are sent without it.
+func (s *Service) StartWebhooks(client *http.Client, interval time.Duration) (stop func()) {
+	return s.StartWebhooksContext(context.Background(), client, interval)
+}
+
+//StartWebhooksContext is StartWebhooks which also stops when ctx is done, ctx is passed to every request
+func (s *Service) StartWebhooksContext(ctx context.Context, client *http.Client, interval time.Duration) (stop func()) {
+
+	return s.startLoop(ctx, interval, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.deliverWebhooks(newTracker(ctx, "deliver webhooks", 0), client, &s.lock)
+	})
+}
+
+//SignWebhook returns hex HMAC-SHA256 of body
+func SignWebhook(secret string, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//VerifyWebhook checks signature of body, used by receivers
+func VerifyWebhook(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, body)), []byte(signature))
+}
+
+func (s *Service) enqueueWebhooks(event types.Event) {
+
+	if event.Payment == nil || len(s.webhooks) == 0 {
+		return
+	}
+	switch event.Type {
+	case types.EventPaymentCreated, types.EventPaymentConfirmed, types.EventPaymentRejected:
+	default:
+		return
+	}
+
+	payload, err := json.Marshal(WebhookPayload{
+		EventID: event.ID,
+		Type:    event.Type,
+		From:    event.From,
+		To:      event.Payment.Status,
+		At:      event.At,
+		Payment: WebhookPayment{
+			ID:        event.Payment.ID,
+			AccountID: event.Payment.AccountID,
+			Amount:    event.Payment.Amount,
+			Category:  event.Payment.Category,
+			Status:    event.Payment.Status,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, v := range s.webhooks {
+		if v.AccountID != 0 && v.AccountID != event.Payment.AccountID {
+			continue
+		}
+		if v.Category != "" && v.Category != event.Payment.Category {
+			continue
+		}
+		s.deliveries = append(s.deliveries, &types.WebhookDelivery{
+			ID:          uuid.New().String(),
+			WebhookID:   v.ID,
+			EventID:     event.ID,
+			Payload:     payload,
+			NextAttempt: event.At,
+		})
+	}
+}
+
+func (s *Service) findWebhook(webhookID string) *types.Webhook {
+
+	for _, v := range s.webhooks {
+		if v.ID == webhookID {
+			return v
+		}
+	}
+	return nil
+}
+
+func postWebhook(ctx context.Context, client *http.Client, webhook *types.Webhook, secret string, delivery *types.WebhookDelivery) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, delivery.Payload))
+	req.Header.Set("X-Wallet-Delivery", delivery.ID)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package wallet
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khushbakhtmahkamov/wallet/pkg/types"
+)
+
+func TestService_DeliverWebhooks_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	mu := sync.Mutex{}
+	fail := true
+	var payloads []WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !VerifyWebhook("secret", body, r.Header.Get(WebhookSignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var payload WebhookPayload
+		json.Unmarshal(body, &payload)
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	_, err := svc.RegisterWebhook(server.URL, "secret", account.ID, "Cafe")
+	if err != nil {
+		t.Fatalf("method RegisterWebhook returned not nil error, error => %v", err)
+	}
+
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	svc.Pay(account.ID, 10_00, "Taxi")
+	svc.Reject(payment.ID)
+
+	if svc.DeliverWebhooks(server.Client()) != 0 {
+		t.Errorf("webhook delivered to failing server")
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	clock.now = clock.now.Add(relayBackoff)
+	if got := svc.DeliverWebhooks(server.Client()); got != 2 {
+		t.Fatalf("wrong delivered count => %v", got)
+	}
+
+	if payloads[0].To != types.PaymentStatusInProgress || payloads[0].Payment.ID != payment.ID {
+		t.Errorf("wrong created payload => %v", payloads[0])
+	}
+	if payloads[1].From != types.PaymentStatusInProgress || payloads[1].To != types.PaymentStatusFail {
+		t.Errorf("wrong rejected payload => %v", payloads[1])
+	}
+}
+
+func TestService_DeliverWebhooks_deadLetter_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.RegisterWebhook(server.URL, "secret", 0, "")
+	svc.Pay(account.ID, 10_00, "Cafe")
+
+	for i := 0; i < webhookMaxAttempts; i++ {
+		svc.DeliverWebhooks(server.Client())
+		clock.now = clock.now.Add(relayMaxBackoff)
+	}
+
+	letters := svc.WebhookDeadLetters()
+	if len(letters) != 1 || letters[0].Attempts != webhookMaxAttempts || len(svc.deliveries) != 0 {
+		t.Errorf("delivery not moved to dead letters => %v", letters)
+	}
+}
+
+func TestService_DeliverWebhooks_keepsOrder_user(t *testing.T) {
+	var svc Service
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	svc.SetClock(clock)
+
+	mu := sync.Mutex{}
+	requests := 0
+	var payloads []WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.RegisterWebhook(server.URL, "secret", account.ID, "")
+	payment, _ := svc.Pay(account.ID, 10_00, "Cafe")
+	svc.Reject(payment.ID)
+
+	if svc.DeliverWebhooks(server.Client()) != 0 || requests != 1 {
+		t.Errorf("later delivery was sent while first one waits, requests => %d", requests)
+	}
+	clock.now = clock.now.Add(relayBackoff)
+	if svc.DeliverWebhooks(server.Client()) != 2 {
+		t.Fatalf("deliveries were not sent after backoff => %v", payloads)
+	}
+	if payloads[0].Type != types.EventPaymentCreated || payloads[1].Type != types.EventPaymentRejected {
+		t.Errorf("deliveries out of order => %v", payloads)
+	}
+}
+
+func TestService_RegisterWebhook_hidesSecret_user(t *testing.T) {
+	var svc Service
+
+	webhook, err := svc.RegisterWebhook("https://example.com/hook", "top-secret", 0, "")
+	if err != nil {
+		t.Fatalf("method RegisterWebhook returned not nil error, error => %v", err)
+	}
+	data, _ := json.Marshal(webhook)
+	if strings.Contains(string(data), "top-secret") {
+		t.Errorf("secret is returned => %s", data)
+	}
+	if defaultWebhookClient.Timeout <= 0 {
+		t.Errorf("default client has no timeout")
+	}
+}
+
+func TestService_StartWebhooks_postsWithoutLock_user(t *testing.T) {
+	var svc Service
+
+	locked := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := make(chan struct{})
+		go func() {
+			svc.Locker().Lock()
+			svc.Locker().Unlock()
+			close(done)
+		}()
+		select {
+		case <-done:
+			locked <- true
+		case <-time.After(time.Second):
+			locked <- false
+		}
+	}))
+	defer server.Close()
+
+	account, _ := svc.RegisterAccount("+992000000001")
+	svc.Deposit(account.ID, 100_00)
+	svc.RegisterWebhook(server.URL, "secret", account.ID, "")
+	svc.Pay(account.ID, 10_00, "Cafe")
+
+	stop := svc.StartWebhooks(server.Client(), time.Millisecond)
+	var got bool
+	select {
+	case got = <-locked:
+	case <-time.After(5 * time.Second):
+	}
+	stop()
+	if !got {
+		t.Errorf("webhook was posted with service lock held")
+	}
+	if len(svc.deliveries) != 0 {
+		t.Errorf("delivery was not recorded => %d", len(svc.deliveries))
+	}
+}